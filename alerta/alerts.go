@@ -0,0 +1,72 @@
+package alerta
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// Alert is an Alerta alert.
+type Alert struct {
+	ID              string                 `json:"id"`
+	Resource        string                 `json:"resource"`
+	Event           string                 `json:"event"`
+	Environment     string                 `json:"environment"`
+	Severity        string                 `json:"severity"`
+	Status          string                 `json:"status"`
+	Service         []string               `json:"service"`
+	Group           string                 `json:"group"`
+	Value           string                 `json:"value"`
+	Text            string                 `json:"text"`
+	Tags            []string               `json:"tags"`
+	Attributes      map[string]interface{} `json:"attributes"`
+	Origin          string                 `json:"origin"`
+	Customer        string                 `json:"customer"`
+	DuplicateCount  int                    `json:"duplicateCount"`
+	CreateTime      time.Time              `json:"createTime"`
+	ReceiveTime     time.Time              `json:"receiveTime"`
+	LastReceiveTime time.Time              `json:"lastReceiveTime"`
+}
+
+// AlertRequest describes an alert to raise. Resource, Event and
+// Environment are required.
+type AlertRequest struct {
+	Resource    string                 `json:"resource"`
+	Event       string                 `json:"event"`
+	Environment string                 `json:"environment"`
+	Severity    string                 `json:"severity,omitempty"`
+	Service     []string               `json:"service,omitempty"`
+	Group       string                 `json:"group,omitempty"`
+	Value       string                 `json:"value,omitempty"`
+	Text        string                 `json:"text,omitempty"`
+	Tags        []string               `json:"tags,omitempty"`
+	Attributes  map[string]interface{} `json:"attributes,omitempty"`
+	Origin      string                 `json:"origin,omitempty"`
+	Type        string                 `json:"type,omitempty"`
+	// Timeout is in seconds.
+	Timeout  int    `json:"timeout,omitempty"`
+	Customer string `json:"customer,omitempty"`
+}
+
+// SendAlert raises an alert. Alerta accepts alerts it suppresses, for
+// example during a blackout, with 202 and without the alert, in which
+// case only the returned ID is set.
+func (c *Client) SendAlert(ctx context.Context, r AlertRequest) (*Alert, error) {
+	env, err := c.call(ctx, http.MethodPost, "/alert", r, http.StatusCreated, http.StatusAccepted)
+	if err != nil {
+		return nil, err
+	}
+
+	var alert Alert
+	if err := env.decodeOptional("alert", &alert); err != nil {
+		return nil, err
+	}
+
+	if alert.ID == "" {
+		if err := env.decodeOptional("id", &alert.ID); err != nil {
+			return nil, err
+		}
+	}
+
+	return &alert, nil
+}
@@ -0,0 +1,120 @@
+package alerta
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Blackout suppresses matching alerts for a period.
+type Blackout struct {
+	ID          string    `json:"id"`
+	Priority    int       `json:"priority"`
+	Environment string    `json:"environment"`
+	Service     []string  `json:"service"`
+	Resource    string    `json:"resource"`
+	Event       string    `json:"event"`
+	Group       string    `json:"group"`
+	Tags        []string  `json:"tags"`
+	Origin      string    `json:"origin"`
+	Customer    string    `json:"customer"`
+	StartTime   time.Time `json:"startTime"`
+	EndTime     time.Time `json:"endTime"`
+	// Duration is the length of the blackout in seconds.
+	Duration int `json:"duration"`
+	// Status is one of "pending", "active" or "expired".
+	Status string `json:"status"`
+	// Remaining is the number of seconds until the blackout ends.
+	Remaining  int       `json:"remaining"`
+	User       string    `json:"user"`
+	CreateTime time.Time `json:"createTime"`
+	Text       string    `json:"text"`
+}
+
+// BlackoutRequest describes a blackout to create, or the fields of a
+// blackout to change. Environment is required on create.
+type BlackoutRequest struct {
+	Environment string   `json:"environment,omitempty"`
+	Service     []string `json:"service,omitempty"`
+	Resource    string   `json:"resource,omitempty"`
+	Event       string   `json:"event,omitempty"`
+	Group       string   `json:"group,omitempty"`
+	Tags        []string `json:"tags,omitempty"`
+	Origin      string   `json:"origin,omitempty"`
+	Customer    string   `json:"customer,omitempty"`
+	// StartTime defaults to now.
+	StartTime time.Time `json:"-"`
+	// EndTime defaults to StartTime plus Duration.
+	EndTime time.Time `json:"-"`
+	// Duration is in seconds, and defaults to Alerta's
+	// BLACKOUT_DURATION.
+	Duration int    `json:"duration,omitempty"`
+	Text     string `json:"text,omitempty"`
+}
+
+func (r BlackoutRequest) MarshalJSON() ([]byte, error) {
+	type request BlackoutRequest
+	return json.Marshal(struct {
+		request
+		StartTime *string `json:"startTime,omitempty"`
+		EndTime   *string `json:"endTime,omitempty"`
+	}{request(r), formatTime(r.StartTime), formatTime(r.EndTime)})
+}
+
+func blackoutEndpoint(id string) string {
+	return "/blackout/" + url.PathEscape(id)
+}
+
+// CreateBlackout creates a blackout.
+func (c *Client) CreateBlackout(ctx context.Context, r BlackoutRequest) (*Blackout, error) {
+	env, err := c.call(ctx, http.MethodPost, "/blackout", r, http.StatusCreated)
+	if err != nil {
+		return nil, err
+	}
+
+	var blackout Blackout
+	if err := env.decode("blackout", &blackout); err != nil {
+		return nil, err
+	}
+
+	return &blackout, nil
+}
+
+// GetBlackout looks up a blackout by ID.
+func (c *Client) GetBlackout(ctx context.Context, id string) (*Blackout, error) {
+	env, err := c.call(ctx, http.MethodGet, blackoutEndpoint(id), nil, http.StatusOK)
+	if err != nil {
+		return nil, err
+	}
+
+	var blackout Blackout
+	if err := env.decode("blackout", &blackout); err != nil {
+		return nil, err
+	}
+
+	return &blackout, nil
+}
+
+// ListBlackouts returns one page of blackouts.
+func (c *Client) ListBlackouts(ctx context.Context, opts *ListOptions) ([]Blackout, *Page, error) {
+	return list[Blackout](ctx, c, "/blackouts", "blackouts", opts)
+}
+
+// ListAllBlackouts returns every blackout that matches filter.
+func (c *Client) ListAllBlackouts(ctx context.Context, filter url.Values) ([]Blackout, error) {
+	return listAll[Blackout](ctx, c, "/blackouts", "blackouts", filter)
+}
+
+// UpdateBlackout changes the set fields of a blackout.
+func (c *Client) UpdateBlackout(ctx context.Context, id string, r BlackoutRequest) error {
+	_, err := c.call(ctx, http.MethodPut, blackoutEndpoint(id), r, http.StatusOK)
+	return err
+}
+
+// DeleteBlackout deletes a blackout, ending it early.
+func (c *Client) DeleteBlackout(ctx context.Context, id string) error {
+	_, err := c.call(ctx, http.MethodDelete, blackoutEndpoint(id), nil, http.StatusOK)
+	return err
+}
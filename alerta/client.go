@@ -0,0 +1,264 @@
+// Package alerta is a client for the Alerta API.
+//
+// It covers the resources the secrets engine manages or relies on:
+// API keys, users, customers, permissions, blackouts, heartbeats
+// and alerts. Every method takes a context, sends the client's key
+// in the Authorization header and tags the request with an
+// X-Request-ID, so that failures can be matched against Alerta's
+// logs.
+package alerta
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/go-uuid"
+)
+
+const (
+	// RequestIDHeader carries the ID of each request. Alerta echoes
+	// it in its response and its logs.
+	RequestIDHeader = "X-Request-ID"
+
+	// DefaultTimeout bounds each request when no HTTP client is given.
+	DefaultTimeout = 10 * time.Second
+)
+
+// Client calls the Alerta API with a single API key. It is safe for
+// concurrent use.
+type Client struct {
+	endpoint   string
+	key        string
+	httpClient *http.Client
+	observer   func(RequestInfo)
+
+	// server is shared with the copies WithKey makes, so that they
+	// see the version detected on any of them.
+	server *serverState
+}
+
+// RequestInfo describes a completed request, for logging and metrics.
+type RequestInfo struct {
+	Method string
+	// Endpoint is the path and query the request was sent to,
+	// relative to the client's endpoint.
+	Endpoint string
+	// StatusCode is zero if no response was received.
+	StatusCode int
+	RequestID  string
+	Start      time.Time
+	Duration   time.Duration
+	// Err is set if the request could not be sent or its
+	// response could not be read.
+	Err error
+}
+
+// Option configures a Client.
+type Option func(*Client)
+
+// WithHTTPClient sends requests with hc instead of a client
+// with DefaultTimeout.
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = hc
+	}
+}
+
+// WithObserver calls fn after every request, whatever its outcome.
+func WithObserver(fn func(RequestInfo)) Option {
+	return func(c *Client) {
+		c.observer = fn
+	}
+}
+
+// NewClient returns a client for the Alerta API at endpoint, such as
+// https://alerta.example.com/api, authenticated with key.
+func NewClient(endpoint, key string, opts ...Option) (*Client, error) {
+	if endpoint == "" {
+		return nil, errors.New("alerta: endpoint is required")
+	}
+
+	if key == "" {
+		return nil, errors.New("alerta: key is required")
+	}
+
+	c := &Client{
+		endpoint:   strings.TrimRight(endpoint, "/"),
+		key:        key,
+		httpClient: &http.Client{Timeout: DefaultTimeout},
+		server:     &serverState{},
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	return c, nil
+}
+
+// Endpoint returns the API endpoint the client sends requests to.
+func (c *Client) Endpoint() string {
+	return c.endpoint
+}
+
+// WithKey returns a copy of the client that authenticates with key.
+func (c *Client) WithKey(key string) *Client {
+	cp := *c
+	cp.key = key
+	return &cp
+}
+
+// Do sends a request with in, if not nil, as its JSON body and
+// decodes the JSON response into out, if not nil. It returns the
+// response status code, and an *Error for any status outside 2xx.
+func (c *Client) Do(ctx context.Context, method, endpoint string, in, out any) (int, error) {
+	resp, body, err := c.send(ctx, method, endpoint, in)
+	if err != nil {
+		return 0, err
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, newError(method, endpoint, resp, body)
+	}
+
+	if out != nil && len(body) > 0 {
+		if err := json.Unmarshal(body, out); err != nil {
+			return resp.StatusCode, fmt.Errorf("alerta: error decoding response: %w", err)
+		}
+	}
+
+	return resp.StatusCode, nil
+}
+
+// send performs a request and reads its whole response body.
+func (c *Client) send(ctx context.Context, method, endpoint string, in any) (*http.Response, []byte, error) {
+	var body io.Reader
+	if in != nil {
+		b, err := json.Marshal(in)
+		if err != nil {
+			return nil, nil, err
+		}
+		body = bytes.NewReader(b)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.endpoint+endpoint, body)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	requestID, err := uuid.GenerateUUID()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	req.Header.Set("Authorization", "Key "+c.key)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(RequestIDHeader, requestID)
+
+	info := RequestInfo{
+		Method:    method,
+		Endpoint:  endpoint,
+		RequestID: requestID,
+		Start:     time.Now(),
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		info.Duration = time.Since(info.Start)
+		info.Err = err
+		c.observe(info)
+		return nil, nil, err
+	}
+
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+
+	if id := resp.Header.Get(RequestIDHeader); id != "" {
+		info.RequestID = id
+	} else {
+		resp.Header.Set(RequestIDHeader, requestID)
+	}
+
+	info.StatusCode = resp.StatusCode
+	info.Duration = time.Since(info.Start)
+	info.Err = err
+	c.observe(info)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return resp, data, nil
+}
+
+func (c *Client) observe(info RequestInfo) {
+	if c.observer != nil {
+		c.observer(info)
+	}
+}
+
+// envelope is the top-level object of an Alerta response.
+type envelope map[string]json.RawMessage
+
+// call sends a request and checks that it returned one of the
+// expected status codes and a status of "ok".
+func (c *Client) call(ctx context.Context, method, endpoint string, in any, expect ...int) (envelope, error) {
+	resp, body, err := c.send(ctx, method, endpoint, in)
+	if err != nil {
+		return nil, err
+	}
+
+	if !slices.Contains(expect, resp.StatusCode) {
+		return nil, newError(method, endpoint, resp, body)
+	}
+
+	var env envelope
+	if err := json.Unmarshal(body, &env); err != nil {
+		return nil, fmt.Errorf("alerta: error decoding response: %w", err)
+	}
+
+	var status string
+	if err := env.decodeOptional("status", &status); err != nil {
+		return nil, err
+	}
+
+	if status != "ok" {
+		e := newError(method, endpoint, resp, body)
+		e.Status = status
+		return nil, e
+	}
+
+	return env, nil
+}
+
+// decode decodes the named field into out. It fails if the field
+// is missing or null.
+func (e envelope) decode(name string, out any) error {
+	raw, ok := e[name]
+	if !ok || string(raw) == "null" {
+		return fmt.Errorf("alerta: response has no %q field", name)
+	}
+	return e.decodeOptional(name, out)
+}
+
+// decodeOptional decodes the named field into out, if it is present.
+func (e envelope) decodeOptional(name string, out any) error {
+	raw, ok := e[name]
+	if !ok {
+		return nil
+	}
+
+	if err := json.Unmarshal(raw, out); err != nil {
+		return fmt.Errorf("alerta: error decoding %q: %w", name, err)
+	}
+
+	return nil
+}
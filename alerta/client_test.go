@@ -0,0 +1,247 @@
+package alerta
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+const testKey = "test-key"
+
+// testServer serves handler and returns a client for it.
+func testServer(t *testing.T, handler http.HandlerFunc, opts ...Option) *Client {
+	t.Helper()
+
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+
+	c, err := NewClient(srv.URL+"/", testKey, opts...)
+	require.NoError(t, err)
+	return c
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func TestNewClient(t *testing.T) {
+	_, err := NewClient("", testKey)
+	require.Error(t, err)
+
+	_, err = NewClient("http://alerta", "")
+	require.Error(t, err)
+
+	c, err := NewClient("http://alerta/api/", testKey)
+	require.NoError(t, err)
+	require.Equal(t, "http://alerta/api", c.Endpoint())
+}
+
+func TestClientKeys(t *testing.T) {
+	var got map[string]interface{}
+	var auth []string
+
+	c := testServer(t, func(w http.ResponseWriter, r *http.Request) {
+		auth = append(auth, r.Header.Get("Authorization"))
+		got = nil
+		require.NotEmpty(t, r.Header.Get(RequestIDHeader))
+
+		switch r.Method + " " + r.URL.Path {
+		case "POST /key":
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&got))
+			writeJSON(w, http.StatusCreated, map[string]interface{}{
+				"status": "ok",
+				"key":    "secret",
+				"data": map[string]interface{}{
+					"id":           "1234",
+					"key":          "secret",
+					"user":         "alice",
+					"scopes":       []string{"read:alerts"},
+					"expireTime":   "2030-01-01T00:00:00.000Z",
+					"lastUsedTime": nil,
+					"customer":     nil,
+				},
+			})
+		case "PUT /key/1234":
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&got))
+			writeJSON(w, http.StatusOK, map[string]interface{}{"status": "ok"})
+		case "GET /key/1234":
+			writeJSON(w, http.StatusOK, map[string]interface{}{
+				"status": "ok",
+				"key": map[string]interface{}{
+					"id":           "1234",
+					"count":        3,
+					"lastUsedTime": "2029-06-01T12:00:00.000Z",
+				},
+			})
+		default:
+			writeJSON(w, http.StatusNotFound, map[string]interface{}{"status": "error", "message": "not found"})
+		}
+	})
+
+	t.Run("Create", func(t *testing.T) {
+		key, err := c.CreateKey(context.Background(), CreateKeyRequest{
+			User:       "alice",
+			Scopes:     []string{"read:alerts"},
+			Text:       "test",
+			ExpireTime: time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC),
+		})
+		require.NoError(t, err)
+		require.Equal(t, "1234", key.ID)
+		require.Equal(t, "secret", key.Key)
+		require.Equal(t, time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC), key.ExpireTime)
+		require.True(t, key.LastUsedTime.IsZero())
+
+		require.Equal(t, "2030-01-01T00:00:00.000Z", got["expireTime"])
+		require.NotContains(t, got, "customer")
+	})
+
+	t.Run("Update", func(t *testing.T) {
+		noCustomer := ""
+		require.NoError(t, c.UpdateKey(context.Background(), "1234", KeyUpdate{Scopes: []string{"read"}, Customer: &noCustomer}))
+		require.Contains(t, got, "customer")
+		require.Nil(t, got["customer"])
+
+		require.NoError(t, c.UpdateKey(context.Background(), "1234", KeyUpdate{Text: "renamed"}))
+		require.NotContains(t, got, "customer")
+		require.NotContains(t, got, "expireTime")
+	})
+
+	t.Run("Get", func(t *testing.T) {
+		key, err := c.GetKey(context.Background(), "1234")
+		require.NoError(t, err)
+		require.Equal(t, 3, key.Count)
+		require.Equal(t, time.Date(2029, 6, 1, 12, 0, 0, 0, time.UTC), key.LastUsedTime)
+	})
+
+	t.Run("Not Found", func(t *testing.T) {
+		err := c.DeleteKey(context.Background(), "5678")
+		require.ErrorIs(t, err, ErrNotFound)
+
+		var apiErr *Error
+		require.ErrorAs(t, err, &apiErr)
+		require.Equal(t, "not found", apiErr.Message)
+		require.Equal(t, "/key/5678", apiErr.Endpoint)
+	})
+
+	t.Run("With Key", func(t *testing.T) {
+		auth = nil
+		_, err := c.WithKey("other").GetKey(context.Background(), "1234")
+		require.NoError(t, err)
+		require.Equal(t, []string{"Key other"}, auth)
+	})
+}
+
+func TestClientErrors(t *testing.T) {
+	status := http.StatusUnauthorized
+	c := testServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(RequestIDHeader, "req-1")
+		if status == http.StatusOK {
+			writeJSON(w, status, map[string]interface{}{"status": "error", "message": "boom"})
+			return
+		}
+		writeJSON(w, status, map[string]interface{}{"status": "error"})
+	})
+
+	for _, tc := range []struct {
+		status int
+		target error
+	}{
+		{http.StatusUnauthorized, ErrUnauthorized},
+		{http.StatusForbidden, ErrForbidden},
+		{http.StatusNotFound, ErrNotFound},
+	} {
+		status = tc.status
+		err := c.DeleteUser(context.Background(), "1")
+		require.ErrorIs(t, err, tc.target)
+		require.Contains(t, err.Error(), strconv.Itoa(tc.status))
+		require.Contains(t, err.Error(), "req-1")
+	}
+
+	status = http.StatusInternalServerError
+	err := c.DeleteUser(context.Background(), "1")
+	require.Error(t, err)
+	require.False(t, errors.Is(err, ErrNotFound))
+
+	// Alerta can answer 200 with an error status.
+	status = http.StatusOK
+	err = c.DeleteUser(context.Background(), "1")
+	var apiErr *Error
+	require.ErrorAs(t, err, &apiErr)
+	require.Equal(t, "error", apiErr.Status)
+	require.Contains(t, err.Error(), "boom")
+}
+
+func TestClientPagination(t *testing.T) {
+	const total = 250
+
+	c := testServer(t, func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/blackouts", r.URL.Path)
+		require.Equal(t, "Production", r.URL.Query().Get("environment"))
+
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		size, _ := strconv.Atoi(r.URL.Query().Get("page-size"))
+
+		var blackouts []map[string]interface{}
+		for i := (page - 1) * size; i < min(page*size, total); i++ {
+			blackouts = append(blackouts, map[string]interface{}{"id": fmt.Sprint(i)})
+		}
+
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"status":    "ok",
+			"blackouts": blackouts,
+			"total":     total,
+			"page":      page,
+			"pageSize":  size,
+			"pages":     (total + size - 1) / size,
+			"more":      page*size < total,
+		})
+	})
+
+	filter := map[string][]string{"environment": {"Production"}}
+
+	blackouts, page, err := c.ListBlackouts(context.Background(), &ListOptions{Page: 2, PageSize: 10, Filter: filter})
+	require.NoError(t, err)
+	require.Len(t, blackouts, 10)
+	require.Equal(t, "10", blackouts[0].ID)
+	require.Equal(t, &Page{Total: total, Page: 2, PageSize: 10, Pages: 25, More: true}, page)
+
+	all, err := c.ListAllBlackouts(context.Background(), filter)
+	require.NoError(t, err)
+	require.Len(t, all, total)
+	require.Equal(t, "249", all[total-1].ID)
+}
+
+func TestClientObserver(t *testing.T) {
+	var infos []RequestInfo
+
+	c := testServer(t, func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusCreated, map[string]interface{}{
+			"status":    "ok",
+			"id":        "hb-1",
+			"heartbeat": map[string]interface{}{"id": "hb-1", "origin": "vault", "timeout": 60},
+		})
+	}, WithObserver(func(info RequestInfo) {
+		infos = append(infos, info)
+	}))
+
+	heartbeat, err := c.SendHeartbeat(context.Background(), HeartbeatRequest{Origin: "vault", Timeout: 60})
+	require.NoError(t, err)
+	require.Equal(t, "hb-1", heartbeat.ID)
+
+	require.Len(t, infos, 1)
+	require.Equal(t, http.MethodPost, infos[0].Method)
+	require.Equal(t, "/heartbeat", infos[0].Endpoint)
+	require.Equal(t, http.StatusCreated, infos[0].StatusCode)
+	require.NotEmpty(t, infos[0].RequestID)
+	require.NoError(t, infos[0].Err)
+}
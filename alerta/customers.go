@@ -0,0 +1,73 @@
+package alerta
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+)
+
+// Customer maps users to a customer. Match is a login, email
+// domain or group that identifies the customer's users.
+type Customer struct {
+	ID       string `json:"id,omitempty"`
+	Match    string `json:"match,omitempty"`
+	Customer string `json:"customer,omitempty"`
+}
+
+func customerEndpoint(id string) string {
+	return "/customer/" + url.PathEscape(id)
+}
+
+// CreateCustomer creates a customer lookup. ID is ignored.
+func (c *Client) CreateCustomer(ctx context.Context, r Customer) (*Customer, error) {
+	r.ID = ""
+	env, err := c.call(ctx, http.MethodPost, "/customer", r, http.StatusCreated)
+	if err != nil {
+		return nil, err
+	}
+
+	var customer Customer
+	if err := env.decode("customer", &customer); err != nil {
+		return nil, err
+	}
+
+	return &customer, nil
+}
+
+// GetCustomer looks up a customer lookup by ID.
+func (c *Client) GetCustomer(ctx context.Context, id string) (*Customer, error) {
+	env, err := c.call(ctx, http.MethodGet, customerEndpoint(id), nil, http.StatusOK)
+	if err != nil {
+		return nil, err
+	}
+
+	var customer Customer
+	if err := env.decode("customer", &customer); err != nil {
+		return nil, err
+	}
+
+	return &customer, nil
+}
+
+// ListCustomers returns one page of customer lookups.
+func (c *Client) ListCustomers(ctx context.Context, opts *ListOptions) ([]Customer, *Page, error) {
+	return list[Customer](ctx, c, "/customers", "customers", opts)
+}
+
+// ListAllCustomers returns every customer lookup that matches filter.
+func (c *Client) ListAllCustomers(ctx context.Context, filter url.Values) ([]Customer, error) {
+	return listAll[Customer](ctx, c, "/customers", "customers", filter)
+}
+
+// UpdateCustomer changes the set fields of a customer lookup.
+func (c *Client) UpdateCustomer(ctx context.Context, id string, r Customer) error {
+	r.ID = ""
+	_, err := c.call(ctx, http.MethodPut, customerEndpoint(id), r, http.StatusOK)
+	return err
+}
+
+// DeleteCustomer deletes a customer lookup.
+func (c *Client) DeleteCustomer(ctx context.Context, id string) error {
+	_, err := c.call(ctx, http.MethodDelete, customerEndpoint(id), nil, http.StatusOK)
+	return err
+}
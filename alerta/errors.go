@@ -0,0 +1,78 @@
+package alerta
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+var (
+	// ErrNotFound matches an *Error for a 404 response, for example
+	// for a key that was already deleted.
+	ErrNotFound = errors.New("alerta: not found")
+
+	// ErrUnauthorized matches an *Error for a 401 response, returned
+	// when the client's key is unknown or has expired.
+	ErrUnauthorized = errors.New("alerta: unauthorized")
+
+	// ErrForbidden matches an *Error for a 403 response, returned
+	// when the client's key lacks a scope the request needs.
+	ErrForbidden = errors.New("alerta: forbidden")
+)
+
+// Error is returned for a response with an unexpected status code,
+// or whose status field is not "ok".
+type Error struct {
+	Method   string
+	Endpoint string
+
+	StatusCode int
+	// Status is the response's status field, if it was not "ok".
+	Status string
+	// Message is the message Alerta gave, if any.
+	Message   string
+	RequestID string
+}
+
+func newError(method, endpoint string, resp *http.Response, body []byte) *Error {
+	var data struct {
+		Message string `json:"message"`
+	}
+	_ = json.Unmarshal(body, &data)
+
+	return &Error{
+		Method:     method,
+		Endpoint:   endpoint,
+		StatusCode: resp.StatusCode,
+		Message:    data.Message,
+		RequestID:  resp.Header.Get(RequestIDHeader),
+	}
+}
+
+func (e *Error) Error() string {
+	msg := fmt.Sprintf("unexpected status code: %d (request ID %s)", e.StatusCode, e.RequestID)
+	if e.Status != "" {
+		msg = fmt.Sprintf("unexpected status: %s (request ID %s)", e.Status, e.RequestID)
+	}
+
+	if e.Message != "" {
+		msg += ": " + e.Message
+	}
+
+	return msg
+}
+
+// Is reports whether e matches ErrNotFound, ErrUnauthorized or
+// ErrForbidden.
+func (e *Error) Is(target error) bool {
+	switch target {
+	case ErrNotFound:
+		return e.StatusCode == http.StatusNotFound
+	case ErrUnauthorized:
+		return e.StatusCode == http.StatusUnauthorized
+	case ErrForbidden:
+		return e.StatusCode == http.StatusForbidden
+	}
+	return false
+}
@@ -0,0 +1,86 @@
+package alerta
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Heartbeat records that an origin is alive. Alerta raises an alert
+// if it is not sent again within its timeout.
+type Heartbeat struct {
+	ID         string                 `json:"id"`
+	Origin     string                 `json:"origin"`
+	Tags       []string               `json:"tags"`
+	Attributes map[string]interface{} `json:"attributes"`
+	Type       string                 `json:"type"`
+	// Timeout is in seconds.
+	Timeout     int       `json:"timeout"`
+	CreateTime  time.Time `json:"createTime"`
+	ReceiveTime time.Time `json:"receiveTime"`
+	// Status is one of "ok", "slow" or "expired".
+	Status   string `json:"status"`
+	Customer string `json:"customer"`
+}
+
+// HeartbeatRequest describes a heartbeat to send.
+type HeartbeatRequest struct {
+	Origin     string                 `json:"origin"`
+	Tags       []string               `json:"tags"`
+	Attributes map[string]interface{} `json:"attributes,omitempty"`
+	// Timeout is in seconds, and defaults to Alerta's
+	// HEARTBEAT_TIMEOUT.
+	Timeout  int    `json:"timeout,omitempty"`
+	Customer string `json:"customer,omitempty"`
+}
+
+func heartbeatEndpoint(id string) string {
+	return "/heartbeat/" + url.PathEscape(id)
+}
+
+// SendHeartbeat creates or refreshes the heartbeat of an origin.
+func (c *Client) SendHeartbeat(ctx context.Context, r HeartbeatRequest) (*Heartbeat, error) {
+	env, err := c.call(ctx, http.MethodPost, "/heartbeat", r, http.StatusCreated)
+	if err != nil {
+		return nil, err
+	}
+
+	var heartbeat Heartbeat
+	if err := env.decodeOptional("heartbeat", &heartbeat); err != nil {
+		return nil, err
+	}
+
+	return &heartbeat, nil
+}
+
+// GetHeartbeat looks up a heartbeat by ID.
+func (c *Client) GetHeartbeat(ctx context.Context, id string) (*Heartbeat, error) {
+	env, err := c.call(ctx, http.MethodGet, heartbeatEndpoint(id), nil, http.StatusOK)
+	if err != nil {
+		return nil, err
+	}
+
+	var heartbeat Heartbeat
+	if err := env.decode("heartbeat", &heartbeat); err != nil {
+		return nil, err
+	}
+
+	return &heartbeat, nil
+}
+
+// ListHeartbeats returns one page of heartbeats.
+func (c *Client) ListHeartbeats(ctx context.Context, opts *ListOptions) ([]Heartbeat, *Page, error) {
+	return list[Heartbeat](ctx, c, "/heartbeats", "heartbeats", opts)
+}
+
+// ListAllHeartbeats returns every heartbeat that matches filter.
+func (c *Client) ListAllHeartbeats(ctx context.Context, filter url.Values) ([]Heartbeat, error) {
+	return listAll[Heartbeat](ctx, c, "/heartbeats", "heartbeats", filter)
+}
+
+// DeleteHeartbeat deletes a heartbeat.
+func (c *Client) DeleteHeartbeat(ctx context.Context, id string) error {
+	_, err := c.call(ctx, http.MethodDelete, heartbeatEndpoint(id), nil, http.StatusOK)
+	return err
+}
@@ -0,0 +1,164 @@
+package alerta
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Key is an Alerta API key.
+type Key struct {
+	ID string `json:"id"`
+	// Key is the secret that authenticates requests.
+	Key    string   `json:"key"`
+	User   string   `json:"user"`
+	Scopes []string `json:"scopes"`
+	Text   string   `json:"text"`
+	// ExpireTime is zero if the key does not expire.
+	ExpireTime time.Time `json:"expireTime"`
+	// Count is the number of requests made with the key.
+	Count int `json:"count"`
+	// LastUsedTime is zero if the key was never used.
+	LastUsedTime time.Time `json:"lastUsedTime"`
+	Customer     string    `json:"customer"`
+}
+
+// UnmarshalJSON decodes a key, accepting the time formats of every
+// supported Alerta release. Missing or empty times are zero.
+func (k *Key) UnmarshalJSON(data []byte) error {
+	type key Key
+	var raw struct {
+		key
+		ExpireTime   json.RawMessage `json:"expireTime"`
+		LastUsedTime json.RawMessage `json:"lastUsedTime"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	expireTime, err := parseTime(raw.ExpireTime)
+	if err != nil {
+		return fmt.Errorf("expireTime: %w", err)
+	}
+
+	lastUsedTime, err := parseTime(raw.LastUsedTime)
+	if err != nil {
+		return fmt.Errorf("lastUsedTime: %w", err)
+	}
+
+	*k = Key(raw.key)
+	k.ExpireTime = expireTime
+	k.LastUsedTime = lastUsedTime
+	return nil
+}
+
+// CreateKeyRequest describes a key to create.
+type CreateKeyRequest struct {
+	User     string   `json:"user,omitempty"`
+	Scopes   []string `json:"scopes,omitempty"`
+	Text     string   `json:"text"`
+	Customer string   `json:"customer,omitempty"`
+	// ExpireTime is left to Alerta's default if zero.
+	ExpireTime time.Time `json:"-"`
+}
+
+func (r CreateKeyRequest) MarshalJSON() ([]byte, error) {
+	type request CreateKeyRequest
+	return json.Marshal(struct {
+		request
+		ExpireTime *string `json:"expireTime,omitempty"`
+	}{request(r), formatTime(r.ExpireTime)})
+}
+
+// KeyUpdate changes an existing key. Unset fields are left as they are.
+type KeyUpdate struct {
+	Scopes []string `json:"scopes,omitempty"`
+	Text   string   `json:"text,omitempty"`
+	// Customer is left unchanged if nil, and cleared if it points
+	// to an empty string.
+	Customer   *string   `json:"-"`
+	ExpireTime time.Time `json:"-"`
+}
+
+func (u KeyUpdate) MarshalJSON() ([]byte, error) {
+	type update KeyUpdate
+	data := struct {
+		update
+		ExpireTime *string          `json:"expireTime,omitempty"`
+		Customer   *json.RawMessage `json:"customer,omitempty"`
+	}{update: update(u), ExpireTime: formatTime(u.ExpireTime)}
+
+	if u.Customer != nil {
+		customer := json.RawMessage("null")
+		if *u.Customer != "" {
+			b, err := json.Marshal(*u.Customer)
+			if err != nil {
+				return nil, err
+			}
+			customer = b
+		}
+		data.Customer = &customer
+	}
+
+	return json.Marshal(data)
+}
+
+func keyEndpoint(id string) string {
+	return "/key/" + url.PathEscape(id)
+}
+
+// CreateKey creates a key and returns it, including its secret.
+func (c *Client) CreateKey(ctx context.Context, r CreateKeyRequest) (*Key, error) {
+	env, err := c.call(ctx, http.MethodPost, "/key", r, http.StatusCreated)
+	if err != nil {
+		return nil, err
+	}
+
+	var key Key
+	if err := env.decode("data", &key); err != nil {
+		return nil, err
+	}
+
+	return &key, nil
+}
+
+// GetKey looks up a key by its ID or its secret.
+func (c *Client) GetKey(ctx context.Context, id string) (*Key, error) {
+	env, err := c.call(ctx, http.MethodGet, keyEndpoint(id), nil, http.StatusOK)
+	if err != nil {
+		return nil, err
+	}
+
+	var key Key
+	if err := env.decode("key", &key); err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+// ListKeys returns one page of the keys visible to the client's key.
+func (c *Client) ListKeys(ctx context.Context, opts *ListOptions) ([]Key, *Page, error) {
+	return list[Key](ctx, c, "/keys", "keys", opts)
+}
+
+// ListAllKeys returns every key visible to the client's key that
+// matches filter, following Alerta's pagination.
+func (c *Client) ListAllKeys(ctx context.Context, filter url.Values) ([]Key, error) {
+	return listAll[Key](ctx, c, "/keys", "keys", filter)
+}
+
+// UpdateKey changes an existing key in place.
+func (c *Client) UpdateKey(ctx context.Context, id string, u KeyUpdate) error {
+	_, err := c.call(ctx, http.MethodPut, keyEndpoint(id), u, http.StatusOK)
+	return err
+}
+
+// DeleteKey deletes a key. It returns an error matching ErrNotFound
+// if the key does not exist.
+func (c *Client) DeleteKey(ctx context.Context, id string) error {
+	_, err := c.call(ctx, http.MethodDelete, keyEndpoint(id), nil, http.StatusOK)
+	return err
+}
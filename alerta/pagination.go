@@ -0,0 +1,107 @@
+package alerta
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// DefaultPageSize is the number of items ListAll methods request
+// per page.
+const DefaultPageSize = 100
+
+// ListOptions selects a page of a list endpoint.
+type ListOptions struct {
+	// Page is the 1-based page number. Zero selects the first page.
+	Page int
+	// PageSize is the number of items per page. Zero uses
+	// Alerta's default.
+	PageSize int
+	// Filter is added to the query, for example user=alice.
+	Filter url.Values
+}
+
+func (o *ListOptions) query() url.Values {
+	q := url.Values{}
+	if o == nil {
+		return q
+	}
+
+	for k, v := range o.Filter {
+		q[k] = v
+	}
+
+	if o.Page > 0 {
+		q.Set("page", strconv.Itoa(o.Page))
+	}
+
+	if o.PageSize > 0 {
+		q.Set("page-size", strconv.Itoa(o.PageSize))
+	}
+
+	return q
+}
+
+// Page describes where a list response sits among all results.
+// Endpoints that do not paginate leave it zero apart from Total.
+type Page struct {
+	Total    int
+	Page     int
+	PageSize int
+	Pages    int
+	More     bool
+}
+
+// list fetches one page of endpoint, whose items are in field.
+func list[T any](ctx context.Context, c *Client, endpoint, field string, opts *ListOptions) ([]T, *Page, error) {
+	if q := opts.query(); len(q) > 0 {
+		endpoint += "?" + q.Encode()
+	}
+
+	env, err := c.call(ctx, http.MethodGet, endpoint, nil, http.StatusOK)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var items []T
+	if err := env.decodeOptional(field, &items); err != nil {
+		return nil, nil, err
+	}
+
+	page := &Page{}
+	for name, dst := range map[string]any{
+		"total":    &page.Total,
+		"page":     &page.Page,
+		"pageSize": &page.PageSize,
+		"pages":    &page.Pages,
+		"more":     &page.More,
+	} {
+		if err := env.decodeOptional(name, dst); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return items, page, nil
+}
+
+// listAll follows endpoint's pages until Alerta reports no more.
+func listAll[T any](ctx context.Context, c *Client, endpoint, field string, filter url.Values) ([]T, error) {
+	var all []T
+	for n := 1; ; n++ {
+		items, page, err := list[T](ctx, c, endpoint, field, &ListOptions{
+			Page:     n,
+			PageSize: DefaultPageSize,
+			Filter:   filter,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		all = append(all, items...)
+
+		if !page.More || len(items) == 0 {
+			return all, nil
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package alerta
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+)
+
+// Permission grants scopes to users with a role. Match is the
+// role name.
+type Permission struct {
+	ID     string   `json:"id,omitempty"`
+	Match  string   `json:"match,omitempty"`
+	Scopes []string `json:"scopes,omitempty"`
+}
+
+func permissionEndpoint(id string) string {
+	return "/perm/" + url.PathEscape(id)
+}
+
+// CreatePermission creates a permission. ID is ignored.
+func (c *Client) CreatePermission(ctx context.Context, r Permission) (*Permission, error) {
+	r.ID = ""
+	env, err := c.call(ctx, http.MethodPost, "/perm", r, http.StatusCreated)
+	if err != nil {
+		return nil, err
+	}
+
+	var perm Permission
+	if err := env.decode("permission", &perm); err != nil {
+		return nil, err
+	}
+
+	return &perm, nil
+}
+
+// GetPermission looks up a permission by ID.
+func (c *Client) GetPermission(ctx context.Context, id string) (*Permission, error) {
+	env, err := c.call(ctx, http.MethodGet, permissionEndpoint(id), nil, http.StatusOK)
+	if err != nil {
+		return nil, err
+	}
+
+	var perm Permission
+	if err := env.decode("permission", &perm); err != nil {
+		return nil, err
+	}
+
+	return &perm, nil
+}
+
+// ListPermissions returns one page of permissions.
+func (c *Client) ListPermissions(ctx context.Context, opts *ListOptions) ([]Permission, *Page, error) {
+	return list[Permission](ctx, c, "/perms", "permissions", opts)
+}
+
+// ListAllPermissions returns every permission that matches filter.
+func (c *Client) ListAllPermissions(ctx context.Context, filter url.Values) ([]Permission, error) {
+	return listAll[Permission](ctx, c, "/perms", "permissions", filter)
+}
+
+// UpdatePermission changes the set fields of a permission.
+func (c *Client) UpdatePermission(ctx context.Context, id string, r Permission) error {
+	r.ID = ""
+	_, err := c.call(ctx, http.MethodPut, permissionEndpoint(id), r, http.StatusOK)
+	return err
+}
+
+// DeletePermission deletes a permission.
+func (c *Client) DeletePermission(ctx context.Context, id string) error {
+	_, err := c.call(ctx, http.MethodDelete, permissionEndpoint(id), nil, http.StatusOK)
+	return err
+}
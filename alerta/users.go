@@ -0,0 +1,96 @@
+package alerta
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// User is an Alerta user.
+type User struct {
+	ID            string                 `json:"id"`
+	Name          string                 `json:"name"`
+	Login         string                 `json:"login"`
+	Email         string                 `json:"email"`
+	Domain        string                 `json:"domain"`
+	Status        string                 `json:"status"`
+	Roles         []string               `json:"roles"`
+	Attributes    map[string]interface{} `json:"attributes"`
+	Text          string                 `json:"text"`
+	EmailVerified bool                   `json:"email_verified"`
+	CreateTime    time.Time              `json:"createTime"`
+	UpdateTime    time.Time              `json:"updateTime"`
+	LastLogin     time.Time              `json:"lastLogin"`
+}
+
+// UserRequest describes a user to create, or the fields of a user
+// to change. Unset fields are left to Alerta's defaults, or as they
+// are on update.
+type UserRequest struct {
+	Name          string                 `json:"name,omitempty"`
+	Login         string                 `json:"login,omitempty"`
+	Email         string                 `json:"email,omitempty"`
+	Password      string                 `json:"password,omitempty"`
+	Status        string                 `json:"status,omitempty"`
+	Roles         []string               `json:"roles,omitempty"`
+	Attributes    map[string]interface{} `json:"attributes,omitempty"`
+	Text          string                 `json:"text,omitempty"`
+	EmailVerified bool                   `json:"email_verified,omitempty"`
+}
+
+func userEndpoint(id string) string {
+	return "/user/" + url.PathEscape(id)
+}
+
+// CreateUser creates a user.
+func (c *Client) CreateUser(ctx context.Context, r UserRequest) (*User, error) {
+	env, err := c.call(ctx, http.MethodPost, "/user", r, http.StatusCreated)
+	if err != nil {
+		return nil, err
+	}
+
+	var user User
+	if err := env.decode("user", &user); err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+// GetUser looks up a user by ID.
+func (c *Client) GetUser(ctx context.Context, id string) (*User, error) {
+	env, err := c.call(ctx, http.MethodGet, userEndpoint(id), nil, http.StatusOK)
+	if err != nil {
+		return nil, err
+	}
+
+	var user User
+	if err := env.decode("user", &user); err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+// ListUsers returns one page of users.
+func (c *Client) ListUsers(ctx context.Context, opts *ListOptions) ([]User, *Page, error) {
+	return list[User](ctx, c, "/users", "users", opts)
+}
+
+// ListAllUsers returns every user that matches filter.
+func (c *Client) ListAllUsers(ctx context.Context, filter url.Values) ([]User, error) {
+	return listAll[User](ctx, c, "/users", "users", filter)
+}
+
+// UpdateUser changes the set fields of a user.
+func (c *Client) UpdateUser(ctx context.Context, id string, r UserRequest) error {
+	_, err := c.call(ctx, http.MethodPut, userEndpoint(id), r, http.StatusOK)
+	return err
+}
+
+// DeleteUser deletes a user.
+func (c *Client) DeleteUser(ctx context.Context, id string) error {
+	_, err := c.call(ctx, http.MethodDelete, userEndpoint(id), nil, http.StatusOK)
+	return err
+}
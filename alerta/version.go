@@ -0,0 +1,79 @@
+package alerta
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+)
+
+// serverState is what the client knows about the server's release.
+type serverState struct {
+	mu      sync.RWMutex
+	version string
+}
+
+func (s *serverState) set(version string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.version = version
+}
+
+// WithServerVersion sets the server version instead of detecting it,
+// for servers that do not expose it.
+func WithServerVersion(version string) Option {
+	return func(c *Client) {
+		c.server.set(version)
+	}
+}
+
+// ServerVersion returns the Alerta release the client detected or
+// was given, or "" if it is unknown.
+func (c *Client) ServerVersion() string {
+	c.server.mu.RLock()
+	defer c.server.mu.RUnlock()
+	return c.server.version
+}
+
+// DetectVersion asks the server for its release and records it for
+// ServerVersion. It reads /management/manifest, and falls back to the
+// version field of /config. It is safe to call while the client is in
+// use, and again after a failure.
+func (c *Client) DetectVersion(ctx context.Context) (string, error) {
+	version, err := c.manifestVersion(ctx)
+	if err != nil || version == "" {
+		var configErr error
+		version, configErr = c.configVersion(ctx)
+		if configErr != nil {
+			return "", errors.Join(err, configErr)
+		}
+	}
+
+	if version == "" {
+		return "", errors.New("alerta: server did not report its version")
+	}
+
+	c.server.set(version)
+	return version, nil
+}
+
+func (c *Client) manifestVersion(ctx context.Context) (string, error) {
+	var manifest struct {
+		Release string `json:"release"`
+	}
+	if _, err := c.Do(ctx, http.MethodGet, "/management/manifest", nil, &manifest); err != nil {
+		return "", fmt.Errorf("error reading manifest: %w", err)
+	}
+	return manifest.Release, nil
+}
+
+func (c *Client) configVersion(ctx context.Context) (string, error) {
+	var config struct {
+		Version string `json:"version"`
+	}
+	if _, err := c.Do(ctx, http.MethodGet, "/config", nil, &config); err != nil {
+		return "", fmt.Errorf("error reading config: %w", err)
+	}
+	return config.Version, nil
+}
@@ -0,0 +1,141 @@
+package alerta
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// fixtureServer serves the key responses in testdata, which Alerta
+// 8.x and 9.x shape alike, as a server of the given release.
+func fixtureServer(t *testing.T, release string) *Client {
+	t.Helper()
+
+	fixture := func(w http.ResponseWriter, name string, status int) {
+		data, err := os.ReadFile(filepath.Join("testdata", name))
+		require.NoError(t, err)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		_, _ = w.Write(data)
+	}
+
+	return testServer(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method + " " + r.URL.Path {
+		case "GET /management/manifest":
+			writeJSON(w, http.StatusOK, map[string]interface{}{"release": release})
+		case "POST /key":
+			fixture(w, "key_create.json", http.StatusCreated)
+		case "GET /key/4c1c4e5e-2f44-4fb6-9d9f-0c1c1d3c4f3b":
+			fixture(w, "key_get.json", http.StatusOK)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+}
+
+func TestClientVersions(t *testing.T) {
+	const (
+		id     = "4c1c4e5e-2f44-4fb6-9d9f-0c1c1d3c4f3b"
+		secret = "mdl0o8wzJ4DFbWY1bXP5BD2W0Jj2M6Nw0AmfsCQp"
+	)
+
+	for _, release := range []string{"8.7.0", "9.0.1"} {
+		t.Run(release, func(t *testing.T) {
+			c := fixtureServer(t, release)
+			ctx := context.Background()
+
+			detected, err := c.DetectVersion(ctx)
+			require.NoError(t, err)
+			require.Equal(t, release, detected)
+			require.Equal(t, release, c.ServerVersion())
+
+			created, err := c.CreateKey(ctx, CreateKeyRequest{User: "test@example.com", Scopes: []string{"write:alerts"}})
+			require.NoError(t, err)
+			require.Equal(t, id, created.ID)
+			require.Equal(t, secret, created.Key)
+			require.Equal(t, time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC), created.ExpireTime)
+			require.True(t, created.LastUsedTime.IsZero())
+
+			got, err := c.GetKey(ctx, id)
+			require.NoError(t, err)
+			require.Equal(t, id, got.ID)
+			require.Equal(t, "acme", got.Customer)
+			require.Equal(t, 3, got.Count)
+			require.Equal(t, time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC), got.ExpireTime)
+			require.Equal(t, time.Date(2029, 6, 1, 12, 30, 0, 0, time.UTC), got.LastUsedTime)
+		})
+	}
+}
+
+func TestDetectVersion(t *testing.T) {
+	t.Run("Config Fallback", func(t *testing.T) {
+		c := testServer(t, func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/config" {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			writeJSON(w, http.StatusOK, map[string]interface{}{"endpoint": "/api", "version": "8.1.0"})
+		})
+
+		release, err := c.DetectVersion(context.Background())
+		require.NoError(t, err)
+		require.Equal(t, "8.1.0", release)
+	})
+
+	t.Run("Unknown", func(t *testing.T) {
+		c := testServer(t, func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNotFound)
+		})
+
+		_, err := c.DetectVersion(context.Background())
+		require.ErrorIs(t, err, ErrNotFound)
+		require.Empty(t, c.ServerVersion())
+	})
+
+	t.Run("Pinned", func(t *testing.T) {
+		c, err := NewClient("http://alerta", testKey, WithServerVersion("v8.0.2"))
+		require.NoError(t, err)
+		require.Equal(t, "v8.0.2", c.ServerVersion())
+	})
+}
+
+func TestParseTime(t *testing.T) {
+	want := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	for _, raw := range []string{
+		`"2030-01-02T03:04:05.000Z"`,
+		`"2030-01-02T03:04:05Z"`,
+		`"2030-01-02T05:04:05+02:00"`,
+		`"2030-01-02T03:04:05"`,
+		`"2030-01-02 03:04:05"`,
+	} {
+		got, err := parseTime([]byte(raw))
+		require.NoError(t, err, raw)
+		require.Equal(t, want, got, raw)
+	}
+
+	for _, raw := range []string{"", "null", `""`} {
+		got, err := parseTime([]byte(raw))
+		require.NoError(t, err)
+		require.True(t, got.IsZero())
+	}
+
+	_, err := parseTime([]byte(`"tomorrow"`))
+	require.Error(t, err)
+}
+
+func TestKeyUnmarshal(t *testing.T) {
+	var key Key
+	require.NoError(t, json.Unmarshal([]byte(`{"id": "1234", "lastUsedTime": "2030-01-02T03:04:05+00:00"}`), &key))
+	require.Equal(t, "1234", key.ID)
+	require.True(t, key.ExpireTime.IsZero())
+	require.Equal(t, time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC), key.LastUsedTime)
+
+	require.Error(t, json.Unmarshal([]byte(`{"id": "1234", "expireTime": "tomorrow"}`), &key))
+}
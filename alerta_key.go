@@ -4,14 +4,19 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/hashicorp/vault/sdk/framework"
 	"github.com/hashicorp/vault/sdk/logical"
+
+	"github.com/hmrks/vault-plugin-secrets-alerta/alerta"
 )
 
 const (
 	alertaKeyType = "alerta_api_key"
+
+	issuedKeyStoragePrefix = "issued/"
 )
 
 // alertaKey defines a secret for the Alerta API Key
@@ -22,6 +27,105 @@ type alertaKey struct {
 	RoleName   string    `json:"role_name"`
 }
 
+// alertaIssuedKey records a key created by this mount, so that
+// changes to its role can be applied while the key is outstanding.
+type alertaIssuedKey struct {
+	ID            string    `json:"id"`
+	RoleName      string    `json:"role_name"`
+	EntityID      string    `json:"entity_id"`
+	Justification string    `json:"justification,omitempty"`
+	IssueTime     time.Time `json:"issue_time"`
+	ExpireTime    time.Time `json:"expire_time"`
+	// LeasePath is the prefix of the key's lease ID.
+	LeasePath string `json:"lease_path,omitempty"`
+	// Imported is set for keys created outside Vault and
+	// adopted through the import path.
+	Imported bool `json:"imported,omitempty"`
+	// IdleRevokeTime is set once the key was deleted for being idle.
+	// The record is kept until the lease ends, so the lease cannot
+	// be renewed.
+	IdleRevokeTime time.Time `json:"idle_revoke_time,omitempty"`
+}
+
+func issuedKeyStoragePath(role, id string) string {
+	return issuedKeyStoragePrefix + role + "/" + id
+}
+
+func setIssuedKey(ctx context.Context, s logical.Storage, key *alertaIssuedKey) error {
+	entry, err := logical.StorageEntryJSON(issuedKeyStoragePath(key.RoleName, key.ID), key)
+	if err != nil {
+		return err
+	}
+
+	return s.Put(ctx, entry)
+}
+
+func getIssuedKey(ctx context.Context, s logical.Storage, role, id string) (*alertaIssuedKey, error) {
+	entry, err := s.Get(ctx, issuedKeyStoragePath(role, id))
+	if err != nil {
+		return nil, err
+	}
+
+	if entry == nil {
+		return nil, nil
+	}
+
+	var key alertaIssuedKey
+	if err := entry.DecodeJSON(&key); err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+// removeIssuedKey deletes the record of an issued key and
+// returns it to its role's quotas, unless that already happened
+// when the key was deleted for being idle.
+func (b *alertaBackend) removeIssuedKey(ctx context.Context, s logical.Storage, role, id string) error {
+	key, err := getIssuedKey(ctx, s, role, id)
+	if err != nil {
+		return err
+	}
+
+	if key == nil {
+		return nil
+	}
+
+	if err := s.Delete(ctx, issuedKeyStoragePath(role, id)); err != nil {
+		return err
+	}
+
+	if !key.IdleRevokeTime.IsZero() {
+		return nil
+	}
+
+	return b.releaseKeys(ctx, s, role, key.EntityID, 1)
+}
+
+// listIssuedKeys returns the IDs of the outstanding keys issued for a role.
+func listIssuedKeys(ctx context.Context, s logical.Storage, role string) ([]string, error) {
+	return s.List(ctx, issuedKeyStoragePrefix+role+"/")
+}
+
+// findIssuedKey looks up the record of an issued key under any role.
+func findIssuedKey(ctx context.Context, s logical.Storage, id string) (*alertaIssuedKey, error) {
+	roles, err := s.List(ctx, issuedKeyStoragePrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, role := range roles {
+		key, err := getIssuedKey(ctx, s, strings.TrimSuffix(role, "/"), id)
+		if err != nil {
+			return nil, err
+		}
+		if key != nil {
+			return key, nil
+		}
+	}
+
+	return nil, nil
+}
+
 // alertaKey defines a secret to store for a given role
 // and how it should be revoked or renewed.
 func (b *alertaBackend) alertaKey() *framework.Secret {
@@ -36,36 +140,92 @@ func (b *alertaBackend) alertaKey() *framework.Secret {
 				Type:        framework.TypeString,
 				Description: "Alerta API key ID",
 			},
+			"alerta_endpoint": {
+				Type:        framework.TypeString,
+				Description: "Alerta API URL the key is valid for",
+			},
 			"expire_time": {
 				Type:        framework.TypeString,
 				Description: "Time the API key expires",
 			},
+			"rendered": {
+				Type:        framework.TypeString,
+				Description: "Endpoint and key rendered in the requested format",
+			},
 		},
 		Revoke: b.keyRevoke,
 		Renew:  b.keyRenew,
 	}
 }
 
-// keyRevoke removes the key from the Vault storage API and calls the client to revoke the key
+// keyRevoke removes the key from the Vault storage API and calls the client to revoke the key.
+// Keys that cannot be deleted are queued and retried by the periodic function.
 func (b *alertaBackend) keyRevoke(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	role, _ := req.Secret.InternalData["role_name"].(string)
+	defer measureSince(time.Now(), role, "key", "revoke")
+
+	apiKeyId, err := secretKeyID(req.Secret.InternalData)
+	if err != nil {
+		return nil, err
+	}
+
 	client, err := b.getClient(ctx, req.Storage)
 	if err != nil {
-		return nil, fmt.Errorf("error getting client: %w", err)
+		err = fmt.Errorf("error getting client: %w", err)
+	} else {
+		err = b.deleteKey(ctx, client, apiKeyId)
 	}
 
+	if err != nil && !errors.Is(err, alerta.ErrNotFound) {
+		b.Logger().Error("error revoking key", "role", role, "key_id", apiKeyId, "lease_id", req.Secret.LeaseID, "error", err)
+		incrCounter(role, "key", "revoke_failure")
+		b.sendEvent(ctx, eventKeyRevokeFailed,
+			logical.EventMetadataOperation, string(req.Operation),
+			"role", role,
+			"key_id", apiKeyId,
+			"lease_id", req.Secret.LeaseID,
+			"error", err.Error())
+
+		// Vault gives up on a lease after a few failed revocations,
+		// so the key is retried from the revocation queue instead.
+		if apiKeyId == "" {
+			return nil, fmt.Errorf("error revoking Alerta API Key: %w", err)
+		}
+		if qErr := b.queueRevocation(ctx, req.Storage, role, apiKeyId, req.Secret.LeaseID, err); qErr != nil {
+			return nil, fmt.Errorf("error revoking Alerta API Key: %w (queueing retry also failed: %v)", err, qErr)
+		}
+		b.Logger().Warn("key revocation queued for retry", "role", role, "key_id", apiKeyId, "lease_id", req.Secret.LeaseID)
+		return nil, nil
+	}
+	b.Logger().Info("key revoked", "role", role, "key_id", apiKeyId, "lease_id", req.Secret.LeaseID)
+	incrCounter(role, "key", "revoked")
+	b.sendEvent(ctx, eventKeyRevoke,
+		logical.EventMetadataOperation, string(req.Operation),
+		"role", role,
+		"key_id", apiKeyId,
+		"lease_id", req.Secret.LeaseID)
+
+	if role != "" && apiKeyId != "" {
+		if err := b.removeIssuedKey(ctx, req.Storage, role, apiKeyId); err != nil {
+			return nil, fmt.Errorf("error removing issued key record: %w", err)
+		}
+	}
+
+	return nil, nil
+}
+
+// secretKeyID returns the Alerta API key ID held by a lease.
+func secretKeyID(internal map[string]interface{}) (string, error) {
 	apiKeyId := ""
-	apiKeyIdRaw, ok := req.Secret.InternalData["alerta_api_key_id"]
+	apiKeyIdRaw, ok := internal["alerta_api_key_id"]
 	if ok {
 		apiKeyId, ok = apiKeyIdRaw.(string)
 		if !ok {
-			return nil, fmt.Errorf("invalid value for Alerta API key ID in secret internal data")
+			return "", fmt.Errorf("invalid value for Alerta API key ID in secret internal data")
 		}
 	}
 
-	if err := b.deleteKey(ctx, client, apiKeyId); err != nil {
-		return nil, fmt.Errorf("error revoking Alerta API Key: %w", err)
-	}
-	return nil, nil
+	return apiKeyId, nil
 }
 
 // keyRenew calls the client to create a new key and stores it in the Vault storage API
@@ -76,6 +236,8 @@ func (b *alertaBackend) keyRenew(ctx context.Context, req *logical.Request, d *f
 	}
 
 	role := roleRaw.(string)
+	defer measureSince(time.Now(), role, "key", "renew")
+
 	roleEntry, err := b.getRole(ctx, req.Storage, role)
 	if err != nil {
 		return nil, fmt.Errorf("error retrieving role: %w", err)
@@ -85,6 +247,21 @@ func (b *alertaBackend) keyRenew(ctx context.Context, req *logical.Request, d *f
 		return nil, errors.New("error retrieving role: role is nil")
 	}
 
+	apiKeyId, err := secretKeyID(req.Secret.InternalData)
+	if err != nil {
+		return nil, err
+	}
+
+	issued, err := getIssuedKey(ctx, req.Storage, role, apiKeyId)
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving issued key: %w", err)
+	}
+
+	if issued != nil && !issued.IdleRevokeTime.IsZero() {
+		return nil, fmt.Errorf("key %s was deleted at %s because it was idle for longer than the role's idle_timeout",
+			apiKeyId, issued.IdleRevokeTime.Format(time.RFC3339))
+	}
+
 	resp := &logical.Response{Secret: req.Secret}
 
 	if roleEntry.TTL > 0 {
@@ -94,31 +271,36 @@ func (b *alertaBackend) keyRenew(ctx context.Context, req *logical.Request, d *f
 		resp.Secret.MaxTTL = roleEntry.MaxTTL
 	}
 
+	b.Logger().Debug("lease renewed", "role", role, "lease_id", req.Secret.LeaseID, "ttl", resp.Secret.TTL)
+	incrCounter(role, "key", "renewed")
+
 	return resp, nil
 }
 
 func (b *alertaBackend) deleteKey(ctx context.Context, c *alertaClient, id string) error {
-	_, err := c.deleteKey(ctx, id)
-	if err != nil {
+	if err := c.DeleteKey(ctx, id); err != nil {
 		return fmt.Errorf("error deleting Alerta API key: %w", err)
 	}
 
 	return nil
 }
 
-func (b *alertaBackend) createKey(ctx context.Context, c *alertaClient, r *alertaRoleEntry) (*alertaKey, error) {
-
-	response, err := c.createKey(ctx, r.User, r.Scopes, fmt.Sprintf("%s at %s", r.Description, time.Now().Format(time.RFC3339)), time.Now().Add(r.MaxTTL).UTC().Format("2006-01-02T15:04:05.000Z"))
-
+func (b *alertaBackend) createKey(ctx context.Context, c *alertaClient, r *alertaRoleEntry, text string) (*alertaKey, error) {
+	expireTime := time.Now().Add(r.MaxTTL)
+	response, err := c.CreateKey(ctx, alerta.CreateKeyRequest{
+		User:       r.User,
+		Scopes:     r.Scopes,
+		Customer:   r.Customer,
+		Text:       text,
+		ExpireTime: expireTime,
+	})
 	if err != nil {
 		return nil, fmt.Errorf("error creating Alerta API Key: %w", err)
 	}
 
-	layout := time.RFC3339
-
-	expireTime, err := time.Parse(layout, response.ExpireTime)
-	if err != nil {
-		return nil, fmt.Errorf("error parsing expire time: %w", err)
+	// some Alerta releases leave expireTime out of the response
+	if !response.ExpireTime.IsZero() {
+		expireTime = response.ExpireTime
 	}
 
 	return &alertaKey{
@@ -0,0 +1,166 @@
+package alertatest
+
+import (
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/hmrks/vault-plugin-secrets-alerta/alerta"
+)
+
+// Alerts returns a copy of every alert the server accepted, in the
+// order they were first raised. Alerts suppressed by a blackout are
+// not kept.
+func (s *Server) Alerts() []alerta.Alert {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return slices.Clone(s.alerts)
+}
+
+func renderAlert(a *alerta.Alert) map[string]interface{} {
+	return map[string]interface{}{
+		"id":              a.ID,
+		"resource":        a.Resource,
+		"event":           a.Event,
+		"environment":     a.Environment,
+		"severity":        a.Severity,
+		"status":          a.Status,
+		"service":         a.Service,
+		"group":           a.Group,
+		"value":           a.Value,
+		"text":            a.Text,
+		"tags":            a.Tags,
+		"attributes":      a.Attributes,
+		"origin":          a.Origin,
+		"customer":        nullable(a.Customer),
+		"duplicateCount":  a.DuplicateCount,
+		"createTime":      formatTime(a.CreateTime),
+		"receiveTime":     formatTime(a.ReceiveTime),
+		"lastReceiveTime": formatTime(a.LastReceiveTime),
+	}
+}
+
+// blackedOut reports whether an active blackout covers the alert.
+// s.mu must be held.
+func (s *Server) blackedOut(a *alerta.AlertRequest, now time.Time) bool {
+	for _, b := range s.blackouts {
+		if now.Before(b.StartTime) || !now.Before(b.EndTime) {
+			continue
+		}
+
+		if b.Environment != a.Environment ||
+			b.Resource != "" && b.Resource != a.Resource ||
+			b.Event != "" && b.Event != a.Event ||
+			b.Group != "" && b.Group != a.Group ||
+			b.Origin != "" && b.Origin != a.Origin ||
+			b.Customer != "" && b.Customer != a.Customer {
+			continue
+		}
+
+		if len(b.Service) > 0 && !slices.ContainsFunc(b.Service, func(service string) bool { return slices.Contains(a.Service, service) }) {
+			continue
+		}
+
+		if !slices.ContainsFunc(b.Tags, func(tag string) bool { return !slices.Contains(a.Tags, tag) }) {
+			return true
+		}
+	}
+	return false
+}
+
+// handleAlert raises an alert, or counts a duplicate of an alert
+// with the same resource, event and environment. Alerts covered by
+// an active blackout are accepted with 202 and dropped.
+func (s *Server) handleAlert(w http.ResponseWriter, r *http.Request) {
+	var req alerta.AlertRequest
+	if !decode(w, r, &req) {
+		return
+	}
+
+	for field, value := range map[string]string{
+		"resource":    req.Resource,
+		"event":       req.Event,
+		"environment": req.Environment,
+	} {
+		if value == "" {
+			writeError(w, http.StatusBadRequest, "Missing "+field)
+			return
+		}
+	}
+
+	if req.Severity == "" {
+		req.Severity = "normal"
+	}
+
+	now := time.Now().UTC()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.blackedOut(&req, now) {
+		writeJSON(w, http.StatusAccepted, map[string]interface{}{
+			"status":  "ok",
+			"id":      s.newID("alert"),
+			"message": "Suppressed alert during blackout period",
+		})
+		return
+	}
+
+	i := slices.IndexFunc(s.alerts, func(a alerta.Alert) bool {
+		return a.Resource == req.Resource && a.Event == req.Event && a.Environment == req.Environment && a.Customer == req.Customer
+	})
+
+	if i < 0 {
+		s.alerts = append(s.alerts, alerta.Alert{
+			ID:          s.newID("alert"),
+			Status:      "open",
+			CreateTime:  now,
+			ReceiveTime: now,
+		})
+		i = len(s.alerts) - 1
+	} else {
+		s.alerts[i].DuplicateCount++
+	}
+
+	alert := &s.alerts[i]
+	alert.Resource = req.Resource
+	alert.Event = req.Event
+	alert.Environment = req.Environment
+	alert.Severity = req.Severity
+	alert.Service = req.Service
+	alert.Group = req.Group
+	alert.Value = req.Value
+	alert.Text = req.Text
+	alert.Tags = req.Tags
+	alert.Attributes = req.Attributes
+	alert.Origin = req.Origin
+	alert.Customer = req.Customer
+	alert.LastReceiveTime = now
+
+	writeJSON(w, http.StatusCreated, map[string]interface{}{
+		"status": "ok",
+		"id":     alert.ID,
+		"alert":  renderAlert(alert),
+	})
+}
+
+// handleAlertsCount reports the number of alerts by severity and
+// status.
+func (s *Server) handleAlertsCount(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	severityCounts, statusCounts := map[string]int{}, map[string]int{}
+	for _, a := range s.alerts {
+		severityCounts[a.Severity]++
+		statusCounts[a.Status]++
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"status":         "ok",
+		"total":          len(s.alerts),
+		"severityCounts": severityCounts,
+		"statusCounts":   statusCounts,
+	})
+}
@@ -0,0 +1,250 @@
+package alertatest
+
+import (
+	"maps"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/hmrks/vault-plugin-secrets-alerta/alerta"
+)
+
+// defaultBlackoutDuration is the length of blackouts created without
+// an end time or duration, as with Alerta's default
+// BLACKOUT_DURATION.
+const defaultBlackoutDuration = time.Hour
+
+// Blackouts returns a copy of every blackout, sorted by ID.
+func (s *Server) Blackouts() []alerta.Blackout {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var blackouts []alerta.Blackout
+	for _, id := range slices.Sorted(maps.Keys(s.blackouts)) {
+		blackouts = append(blackouts, *s.blackouts[id])
+	}
+	return blackouts
+}
+
+// renderBlackout renders a blackout with its status and remaining
+// time as of now, as Alerta does.
+func renderBlackout(b *alerta.Blackout) map[string]interface{} {
+	now := time.Now()
+
+	status, remaining := "active", int(b.EndTime.Sub(now).Seconds())
+	switch {
+	case now.Before(b.StartTime):
+		status, remaining = "pending", b.Duration
+	case !now.Before(b.EndTime):
+		status, remaining = "expired", 0
+	}
+
+	return map[string]interface{}{
+		"id":          b.ID,
+		"priority":    b.Priority,
+		"environment": b.Environment,
+		"service":     b.Service,
+		"resource":    nullable(b.Resource),
+		"event":       nullable(b.Event),
+		"group":       nullable(b.Group),
+		"tags":        b.Tags,
+		"origin":      nullable(b.Origin),
+		"customer":    nullable(b.Customer),
+		"startTime":   formatTime(b.StartTime),
+		"endTime":     formatTime(b.EndTime),
+		"duration":    b.Duration,
+		"status":      status,
+		"remaining":   remaining,
+		"user":        b.User,
+		"createTime":  formatTime(b.CreateTime),
+		"text":        b.Text,
+	}
+}
+
+// blackoutRequest is the body of a blackout create or update request.
+type blackoutRequest struct {
+	Environment *string  `json:"environment"`
+	Service     []string `json:"service"`
+	Resource    *string  `json:"resource"`
+	Event       *string  `json:"event"`
+	Group       *string  `json:"group"`
+	Tags        []string `json:"tags"`
+	Origin      *string  `json:"origin"`
+	Customer    *string  `json:"customer"`
+	StartTime   *string  `json:"startTime"`
+	EndTime     *string  `json:"endTime"`
+	Duration    *int     `json:"duration"`
+	Text        *string  `json:"text"`
+}
+
+// apply copies the fields set in the request to b, and works out the
+// blackout's period.
+func (req *blackoutRequest) apply(w http.ResponseWriter, b *alerta.Blackout) bool {
+	set := func(dst *string, src *string) {
+		if src != nil {
+			*dst = *src
+		}
+	}
+
+	set(&b.Environment, req.Environment)
+	set(&b.Resource, req.Resource)
+	set(&b.Event, req.Event)
+	set(&b.Group, req.Group)
+	set(&b.Origin, req.Origin)
+	set(&b.Customer, req.Customer)
+	set(&b.Text, req.Text)
+
+	if req.Service != nil {
+		b.Service = req.Service
+	}
+	if req.Tags != nil {
+		b.Tags = req.Tags
+	}
+
+	startTime, ok := parseTime(w, req.StartTime)
+	if !ok {
+		return false
+	}
+	endTime, ok := parseTime(w, req.EndTime)
+	if !ok {
+		return false
+	}
+
+	if !startTime.IsZero() {
+		b.StartTime = startTime
+	}
+
+	switch {
+	case !endTime.IsZero():
+		b.EndTime = endTime
+	case req.Duration != nil:
+		b.EndTime = b.StartTime.Add(time.Duration(*req.Duration) * time.Second)
+	case b.EndTime.IsZero():
+		b.EndTime = b.StartTime.Add(defaultBlackoutDuration)
+	}
+
+	if !b.EndTime.After(b.StartTime) {
+		writeError(w, http.StatusBadRequest, "endTime must be after startTime")
+		return false
+	}
+	b.Duration = int(b.EndTime.Sub(b.StartTime).Seconds())
+
+	// Alerta matches more specific blackouts first.
+	b.Priority = 1
+	switch {
+	case b.Resource != "" && b.Event != "":
+		b.Priority = 2
+	case b.Resource != "" || b.Event != "" || b.Group != "" || len(b.Service) > 0 || len(b.Tags) > 0 || b.Origin != "":
+		b.Priority = 3
+	}
+
+	return true
+}
+
+func (s *Server) handleCreateBlackout(w http.ResponseWriter, r *http.Request) {
+	var req blackoutRequest
+	if !decode(w, r, &req) {
+		return
+	}
+
+	if req.Environment == nil || *req.Environment == "" {
+		writeError(w, http.StatusBadRequest, "Missing environment")
+		return
+	}
+
+	now := time.Now().UTC()
+	blackout := &alerta.Blackout{
+		StartTime:  now,
+		User:       "admin",
+		CreateTime: now,
+	}
+	if !req.apply(w, blackout) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	blackout.ID = s.newID("blackout")
+	s.blackouts[blackout.ID] = blackout
+
+	writeJSON(w, http.StatusCreated, map[string]interface{}{
+		"status":   "ok",
+		"id":       blackout.ID,
+		"blackout": renderBlackout(blackout),
+	})
+}
+
+func (s *Server) handleListBlackouts(w http.ResponseWriter, r *http.Request) {
+	environment := r.URL.Query().Get("environment")
+
+	s.mu.Lock()
+	var blackouts []map[string]interface{}
+	for _, id := range slices.Sorted(maps.Keys(s.blackouts)) {
+		blackout := s.blackouts[id]
+		if environment != "" && blackout.Environment != environment {
+			continue
+		}
+		blackouts = append(blackouts, renderBlackout(blackout))
+	}
+	s.mu.Unlock()
+
+	blackouts, resp := paginate(r, blackouts)
+	resp["blackouts"] = append([]map[string]interface{}{}, blackouts...)
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (s *Server) handleGetBlackout(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	blackout, ok := s.blackouts[r.PathValue("id")]
+	if !ok {
+		writeError(w, http.StatusNotFound, "not found")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"status":   "ok",
+		"total":    1,
+		"blackout": renderBlackout(blackout),
+	})
+}
+
+func (s *Server) handleUpdateBlackout(w http.ResponseWriter, r *http.Request) {
+	var req blackoutRequest
+	if !decode(w, r, &req) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	blackout, ok := s.blackouts[r.PathValue("id")]
+	if !ok {
+		writeError(w, http.StatusNotFound, "not found")
+		return
+	}
+
+	updated := *blackout
+	if !req.apply(w, &updated) {
+		return
+	}
+	*blackout = updated
+
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+func (s *Server) handleDeleteBlackout(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id := r.PathValue("id")
+	if _, ok := s.blackouts[id]; !ok {
+		writeError(w, http.StatusNotFound, "not found")
+		return
+	}
+	delete(s.blackouts, id)
+
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
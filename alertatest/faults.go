@@ -0,0 +1,96 @@
+package alertatest
+
+import (
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Fault makes matching requests misbehave.
+type Fault struct {
+	// Method and Path select the requests the fault applies to. An
+	// empty Method matches any method, and Path matches any request
+	// path that starts with it.
+	Method string
+	Path   string
+
+	// Skip lets this many matching requests through before the
+	// fault applies.
+	Skip int
+	// Times limits the number of requests the fault applies to.
+	// Zero applies it until ClearFaults is called.
+	Times int
+
+	// Latency delays the response, or until the client gives up.
+	Latency time.Duration
+	// Status, if set, answers the request with this status code
+	// instead of handling it. 401 is answered as Alerta answers an
+	// invalid key.
+	Status int
+}
+
+type fault struct {
+	Fault
+	seen int
+}
+
+// Inject adds a fault. When several faults match a request, their
+// latencies add up and the first with a status answers it.
+func (s *Server) Inject(f Fault) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.faults = append(s.faults, &fault{Fault: f})
+}
+
+// ClearFaults removes every injected fault.
+func (s *Server) ClearFaults() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.faults = nil
+}
+
+// injectFault applies the faults matching r, and reports whether one
+// of them answered it.
+func (s *Server) injectFault(w http.ResponseWriter, r *http.Request) bool {
+	var latency time.Duration
+	status := 0
+
+	s.mu.Lock()
+	for _, f := range s.faults {
+		if f.Method != "" && f.Method != r.Method || !strings.HasPrefix(r.URL.Path, f.Path) {
+			continue
+		}
+
+		f.seen++
+		if f.seen <= f.Skip || f.Times > 0 && f.seen > f.Skip+f.Times {
+			continue
+		}
+
+		latency += f.Latency
+		if status == 0 {
+			status = f.Status
+		}
+	}
+	s.mu.Unlock()
+
+	if latency > 0 {
+		select {
+		case <-time.After(latency):
+		case <-r.Context().Done():
+			return true
+		}
+	}
+
+	switch {
+	case status == 0:
+		return false
+	case status == http.StatusUnauthorized:
+		writeError(w, status, "API key parameter 'key' is invalid")
+	default:
+		writeError(w, status, http.StatusText(status))
+	}
+
+	return true
+}
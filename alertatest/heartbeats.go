@@ -0,0 +1,150 @@
+package alertatest
+
+import (
+	"maps"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/hmrks/vault-plugin-secrets-alerta/alerta"
+)
+
+// defaultHeartbeatTimeout is the timeout of heartbeats sent without
+// one, as with Alerta's default HEARTBEAT_TIMEOUT.
+const defaultHeartbeatTimeout = 86400
+
+// Heartbeats returns a copy of every heartbeat, sorted by ID.
+func (s *Server) Heartbeats() []alerta.Heartbeat {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var heartbeats []alerta.Heartbeat
+	for _, id := range slices.Sorted(maps.Keys(s.heartbeats)) {
+		heartbeats = append(heartbeats, *s.heartbeats[id])
+	}
+	return heartbeats
+}
+
+// renderHeartbeat renders a heartbeat with its status as of now.
+func renderHeartbeat(h *alerta.Heartbeat) map[string]interface{} {
+	status := "ok"
+	if time.Since(h.ReceiveTime) > time.Duration(h.Timeout)*time.Second {
+		status = "expired"
+	}
+
+	return map[string]interface{}{
+		"id":          h.ID,
+		"origin":      h.Origin,
+		"tags":        h.Tags,
+		"attributes":  h.Attributes,
+		"type":        h.Type,
+		"timeout":     h.Timeout,
+		"createTime":  formatTime(h.CreateTime),
+		"receiveTime": formatTime(h.ReceiveTime),
+		"status":      status,
+		"customer":    nullable(h.Customer),
+	}
+}
+
+// handleHeartbeat creates the heartbeat of an origin, or refreshes
+// it if the origin already sent one.
+func (s *Server) handleHeartbeat(w http.ResponseWriter, r *http.Request) {
+	var req alerta.HeartbeatRequest
+	if !decode(w, r, &req) {
+		return
+	}
+
+	if req.Origin == "" {
+		writeError(w, http.StatusBadRequest, "Missing origin")
+		return
+	}
+
+	if req.Tags == nil {
+		req.Tags = []string{}
+	}
+	if req.Attributes == nil {
+		req.Attributes = map[string]interface{}{}
+	}
+	if req.Timeout <= 0 {
+		req.Timeout = defaultHeartbeatTimeout
+	}
+
+	now := time.Now().UTC()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var heartbeat *alerta.Heartbeat
+	for _, h := range s.heartbeats {
+		if h.Origin == req.Origin && h.Customer == req.Customer {
+			heartbeat = h
+			break
+		}
+	}
+
+	if heartbeat == nil {
+		heartbeat = &alerta.Heartbeat{
+			ID:         s.newID("heartbeat"),
+			Origin:     req.Origin,
+			Type:       "Heartbeat",
+			Customer:   req.Customer,
+			CreateTime: now,
+		}
+		s.heartbeats[heartbeat.ID] = heartbeat
+	}
+
+	heartbeat.Tags = req.Tags
+	heartbeat.Attributes = req.Attributes
+	heartbeat.Timeout = req.Timeout
+	heartbeat.ReceiveTime = now
+
+	writeJSON(w, http.StatusCreated, map[string]interface{}{
+		"status":    "ok",
+		"id":        heartbeat.ID,
+		"heartbeat": renderHeartbeat(heartbeat),
+	})
+}
+
+func (s *Server) handleListHeartbeats(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	var heartbeats []map[string]interface{}
+	for _, id := range slices.Sorted(maps.Keys(s.heartbeats)) {
+		heartbeats = append(heartbeats, renderHeartbeat(s.heartbeats[id]))
+	}
+	s.mu.Unlock()
+
+	heartbeats, resp := paginate(r, heartbeats)
+	resp["heartbeats"] = append([]map[string]interface{}{}, heartbeats...)
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (s *Server) handleGetHeartbeat(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	heartbeat, ok := s.heartbeats[r.PathValue("id")]
+	if !ok {
+		writeError(w, http.StatusNotFound, "not found")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"status":    "ok",
+		"total":     1,
+		"heartbeat": renderHeartbeat(heartbeat),
+	})
+}
+
+func (s *Server) handleDeleteHeartbeat(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id := r.PathValue("id")
+	if _, ok := s.heartbeats[id]; !ok {
+		writeError(w, http.StatusNotFound, "not found")
+		return
+	}
+	delete(s.heartbeats, id)
+
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
@@ -0,0 +1,257 @@
+package alertatest
+
+import (
+	"maps"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/hmrks/vault-plugin-secrets-alerta/alerta"
+)
+
+// defaultKeyLifetime is how long keys created without an expiry
+// last, as with Alerta's default API_KEY_EXPIRE_DAYS.
+const defaultKeyLifetime = 365 * 24 * time.Hour
+
+// AddKey stores a key as if it had been created outside the code
+// under test, and returns a copy of it. The ID and secret are
+// generated if empty.
+func (s *Server) AddKey(key alerta.Key) *alerta.Key {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if key.ID == "" {
+		key.ID = s.newID("key")
+	}
+	if key.Key == "" {
+		key.Key = "secret-" + key.ID
+	}
+
+	s.keys[key.ID] = &key
+	k := key
+	return &k
+}
+
+// Key returns a copy of the key with the given ID, or nil.
+func (s *Server) Key(id string) *alerta.Key {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key, ok := s.keys[id]
+	if !ok {
+		return nil
+	}
+	k := *key
+	return &k
+}
+
+// Keys returns a copy of every key, sorted by ID.
+func (s *Server) Keys() []alerta.Key {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var keys []alerta.Key
+	for _, id := range slices.Sorted(maps.Keys(s.keys)) {
+		keys = append(keys, *s.keys[id])
+	}
+	return keys
+}
+
+// KeyCount returns the number of keys held by the server.
+func (s *Server) KeyCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return len(s.keys)
+}
+
+// UseKey records a use of a key at the given time, as Alerta does
+// when a request authenticates with it.
+func (s *Server) UseKey(id string, at time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if key, ok := s.keys[id]; ok {
+		key.Count++
+		key.LastUsedTime = at.UTC()
+	}
+}
+
+// findKey looks a key up by its ID or its secret, as Alerta's
+// /key/<id> endpoints do. s.mu must be held.
+func (s *Server) findKey(idOrKey string) *alerta.Key {
+	if key, ok := s.keys[idOrKey]; ok {
+		return key
+	}
+
+	for _, key := range s.keys {
+		if key.Key == idOrKey {
+			return key
+		}
+	}
+
+	return nil
+}
+
+func renderKey(k *alerta.Key) map[string]interface{} {
+	return map[string]interface{}{
+		"id":           k.ID,
+		"key":          k.Key,
+		"user":         k.User,
+		"scopes":       k.Scopes,
+		"text":         k.Text,
+		"expireTime":   formatTime(k.ExpireTime),
+		"count":        k.Count,
+		"lastUsedTime": formatTime(k.LastUsedTime),
+		"customer":     nullable(k.Customer),
+	}
+}
+
+func (s *Server) handleCreateKey(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		User       string   `json:"user"`
+		Scopes     []string `json:"scopes"`
+		Text       string   `json:"text"`
+		ExpireTime *string  `json:"expireTime"`
+		Customer   string   `json:"customer"`
+	}
+	if !decode(w, r, &req) {
+		return
+	}
+
+	expireTime, ok := parseTime(w, req.ExpireTime)
+	if !ok {
+		return
+	}
+	if expireTime.IsZero() {
+		expireTime = time.Now().Add(defaultKeyLifetime).UTC()
+	}
+
+	if len(req.Scopes) == 0 {
+		req.Scopes = []string{"read", "write"}
+	}
+
+	if req.Text == "" {
+		req.Text = "API Key for " + req.User
+	}
+
+	s.mu.Lock()
+	id := s.newID("key")
+	key := &alerta.Key{
+		ID:         id,
+		Key:        "secret-" + id,
+		User:       req.User,
+		Scopes:     req.Scopes,
+		Text:       req.Text,
+		ExpireTime: expireTime,
+		Customer:   req.Customer,
+	}
+	s.keys[id] = key
+	data := renderKey(key)
+	s.mu.Unlock()
+
+	writeJSON(w, http.StatusCreated, map[string]interface{}{
+		"status": "ok",
+		"key":    key.Key,
+		"data":   data,
+	})
+}
+
+func (s *Server) handleListKeys(w http.ResponseWriter, r *http.Request) {
+	user, customer := r.URL.Query().Get("user"), r.URL.Query().Get("customer")
+
+	s.mu.Lock()
+	var keys []map[string]interface{}
+	for _, id := range slices.Sorted(maps.Keys(s.keys)) {
+		key := s.keys[id]
+		if user != "" && key.User != user || customer != "" && key.Customer != customer {
+			continue
+		}
+		keys = append(keys, renderKey(key))
+	}
+	s.mu.Unlock()
+
+	keys, resp := paginate(r, keys)
+	resp["keys"] = append([]map[string]interface{}{}, keys...)
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (s *Server) handleGetKey(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := s.findKey(r.PathValue("id"))
+	if key == nil {
+		writeError(w, http.StatusNotFound, "not found")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"status": "ok",
+		"total":  1,
+		"key":    renderKey(key),
+	})
+}
+
+func (s *Server) handleUpdateKey(w http.ResponseWriter, r *http.Request) {
+	var update struct {
+		User       *string  `json:"user"`
+		Scopes     []string `json:"scopes"`
+		Text       *string  `json:"text"`
+		ExpireTime *string  `json:"expireTime"`
+		Customer   *string  `json:"customer"`
+	}
+	fields, ok := decodeUpdate(w, r, &update)
+	if !ok {
+		return
+	}
+
+	expireTime, ok := parseTime(w, update.ExpireTime)
+	if !ok {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := s.findKey(r.PathValue("id"))
+	if key == nil {
+		writeError(w, http.StatusNotFound, "not found")
+		return
+	}
+
+	if update.User != nil {
+		key.User = *update.User
+	}
+	if _, ok := fields["scopes"]; ok {
+		key.Scopes = update.Scopes
+	}
+	if update.Text != nil {
+		key.Text = *update.Text
+	}
+	if !expireTime.IsZero() {
+		key.ExpireTime = expireTime
+	}
+	if _, ok := fields["customer"]; ok {
+		key.Customer = ""
+		if update.Customer != nil {
+			key.Customer = *update.Customer
+		}
+	}
+
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+func (s *Server) handleDeleteKey(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := s.findKey(r.PathValue("id"))
+	if key == nil {
+		writeError(w, http.StatusNotFound, "not found")
+		return
+	}
+	delete(s.keys, key.ID)
+
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
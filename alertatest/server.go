@@ -0,0 +1,330 @@
+// Package alertatest provides an in-memory Alerta API for tests.
+//
+// Server implements the key, user, blackout and heartbeat endpoints,
+// raising and counting alerts, and the version manifest, with the
+// status codes, JSON envelopes and authentication of a real Alerta
+// API. Faults such as latency, server errors or rejected keys can be
+// injected per endpoint to exercise error handling.
+package alertatest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hmrks/vault-plugin-secrets-alerta/alerta"
+)
+
+// DefaultPageSize is the page size list endpoints use when the
+// request does not set page-size.
+const DefaultPageSize = 50
+
+// DefaultVersion is the Alerta release the server reports unless
+// SetVersion changes it.
+const DefaultVersion = "9.0.1"
+
+// Server is an in-memory Alerta API. Its methods are safe to call
+// while requests are being served.
+type Server struct {
+	*httptest.Server
+
+	// adminKey authenticates with every scope. It is not one of
+	// the server's keys, so it is never listed or counted.
+	adminKey string
+
+	mu         sync.Mutex
+	version    string
+	nextID     int
+	keys       map[string]*alerta.Key
+	users      map[string]*alerta.User
+	blackouts  map[string]*alerta.Blackout
+	heartbeats map[string]*alerta.Heartbeat
+	alerts     []alerta.Alert
+	faults     []*fault
+}
+
+// NewServer starts a server that accepts adminKey with every scope.
+// It is closed when the test ends.
+func NewServer(tb testing.TB, adminKey string) *Server {
+	tb.Helper()
+
+	s := &Server{
+		adminKey:   adminKey,
+		version:    DefaultVersion,
+		keys:       map[string]*alerta.Key{},
+		users:      map[string]*alerta.User{},
+		blackouts:  map[string]*alerta.Blackout{},
+		heartbeats: map[string]*alerta.Heartbeat{},
+	}
+
+	mux := http.NewServeMux()
+	s.handle(mux, "GET /management/manifest", "", s.handleManifest)
+
+	s.handle(mux, "POST /key", "write:keys", s.handleCreateKey)
+	s.handle(mux, "GET /keys", "read:keys", s.handleListKeys)
+	s.handle(mux, "GET /key/{id}", "read:keys", s.handleGetKey)
+	s.handle(mux, "PUT /key/{id}", "write:keys", s.handleUpdateKey)
+	s.handle(mux, "DELETE /key/{id}", "write:keys", s.handleDeleteKey)
+
+	s.handle(mux, "POST /user", "admin:users", s.handleCreateUser)
+	s.handle(mux, "GET /users", "admin:users", s.handleListUsers)
+	s.handle(mux, "GET /user/{id}", "admin:users", s.handleGetUser)
+	s.handle(mux, "PUT /user/{id}", "admin:users", s.handleUpdateUser)
+	s.handle(mux, "DELETE /user/{id}", "admin:users", s.handleDeleteUser)
+
+	s.handle(mux, "POST /blackout", "write:blackouts", s.handleCreateBlackout)
+	s.handle(mux, "GET /blackouts", "read:blackouts", s.handleListBlackouts)
+	s.handle(mux, "GET /blackout/{id}", "read:blackouts", s.handleGetBlackout)
+	s.handle(mux, "PUT /blackout/{id}", "write:blackouts", s.handleUpdateBlackout)
+	s.handle(mux, "DELETE /blackout/{id}", "write:blackouts", s.handleDeleteBlackout)
+
+	s.handle(mux, "POST /heartbeat", "write:heartbeats", s.handleHeartbeat)
+	s.handle(mux, "GET /heartbeats", "read:heartbeats", s.handleListHeartbeats)
+	s.handle(mux, "GET /heartbeat/{id}", "read:heartbeats", s.handleGetHeartbeat)
+	s.handle(mux, "DELETE /heartbeat/{id}", "write:heartbeats", s.handleDeleteHeartbeat)
+
+	s.handle(mux, "POST /alert", "write:alerts", s.handleAlert)
+	s.handle(mux, "GET /alerts/count", "read:alerts", s.handleAlertsCount)
+
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		writeError(w, http.StatusNotFound, "The requested URL was not found on the server.")
+	})
+
+	s.Server = httptest.NewServer(mux)
+	tb.Cleanup(s.Close)
+
+	return s
+}
+
+// Client returns a client for the server authenticated with the
+// admin key.
+func (s *Server) Client(tb testing.TB) *alerta.Client {
+	tb.Helper()
+
+	c, err := alerta.NewClient(s.URL, s.adminKey)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return c
+}
+
+// SetVersion changes the Alerta release the server reports.
+func (s *Server) SetVersion(version string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.version = version
+}
+
+// handleManifest reports the server's release, without needing a key.
+func (s *Server) handleManifest(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	version := s.version
+	s.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"build":    "",
+		"date":     "",
+		"release":  version,
+		"revision": "",
+	})
+}
+
+// handle registers a handler for pattern that needs scope, or no key
+// at all if scope is empty. Injected faults apply first, then the
+// request's key is authenticated as Alerta does.
+func (s *Server) handle(mux *http.ServeMux, pattern, scope string, h http.HandlerFunc) {
+	mux.HandleFunc(pattern, func(w http.ResponseWriter, r *http.Request) {
+		if id := r.Header.Get(alerta.RequestIDHeader); id != "" {
+			w.Header().Set(alerta.RequestIDHeader, id)
+		}
+
+		if s.injectFault(w, r) {
+			return
+		}
+
+		if scope != "" && !s.authorize(w, r, scope) {
+			return
+		}
+
+		h(w, r)
+	})
+}
+
+// authorize checks the request's key and records its use.
+func (s *Server) authorize(w http.ResponseWriter, r *http.Request, scope string) bool {
+	secret, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Key ")
+	if !ok || secret == "" {
+		writeError(w, http.StatusUnauthorized, "Missing authorization API Key or Bearer Token")
+		return false
+	}
+
+	if secret == s.adminKey {
+		return true
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := s.findKey(secret)
+	if key == nil || key.Key != secret {
+		writeError(w, http.StatusUnauthorized, "API key parameter 'key' is invalid")
+		return false
+	}
+
+	if !key.ExpireTime.IsZero() && key.ExpireTime.Before(time.Now()) {
+		writeError(w, http.StatusUnauthorized, "API key has expired")
+		return false
+	}
+
+	key.Count++
+	key.LastUsedTime = time.Now().UTC()
+
+	if !inScope(scope, key.Scopes) {
+		writeError(w, http.StatusForbidden, "Missing required scope: "+scope)
+		return false
+	}
+
+	return true
+}
+
+// inScope reports whether scopes grant want, following Alerta's
+// rules: a permission covers all its resources, write implies read
+// and admin implies write.
+func inScope(want string, scopes []string) bool {
+	for _, have := range scopes {
+		if have == want || have == strings.SplitN(want, ":", 2)[0] {
+			return true
+		}
+	}
+
+	if rest, ok := strings.CutPrefix(want, "read"); ok {
+		return inScope("write"+rest, scopes)
+	}
+
+	if rest, ok := strings.CutPrefix(want, "write"); ok {
+		return inScope("admin"+rest, scopes)
+	}
+
+	return false
+}
+
+// newID returns a fresh ID with the given prefix. s.mu must be held.
+func (s *Server) newID(prefix string) string {
+	s.nextID++
+	return fmt.Sprintf("%s-%d", prefix, s.nextID)
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// writeError writes an error in Alerta's format.
+func writeError(w http.ResponseWriter, code int, message string) {
+	writeJSON(w, code, map[string]interface{}{
+		"status":  "error",
+		"message": message,
+		"code":    code,
+		"errors":  nil,
+	})
+}
+
+// decode reads the JSON body of a request, answering 400 if it is
+// not valid.
+func decode(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return false
+	}
+	return true
+}
+
+// decodeUpdate reads the JSON body of an update request into v, and
+// returns the fields it set so that a null can be told apart from a
+// missing field.
+func decodeUpdate(w http.ResponseWriter, r *http.Request, v interface{}) (map[string]json.RawMessage, bool) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return nil, false
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(body, &fields); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return nil, false
+	}
+
+	if err := json.Unmarshal(body, v); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return nil, false
+	}
+
+	return fields, true
+}
+
+// formatTime renders a time as Alerta does, or null if it is zero.
+func formatTime(t time.Time) interface{} {
+	if t.IsZero() {
+		return nil
+	}
+	return t.UTC().Format(alerta.TimeLayout)
+}
+
+// parseTime parses a time from a request, in the only format
+// Alerta accepts. A nil value leaves the time zero.
+func parseTime(w http.ResponseWriter, value *string) (time.Time, bool) {
+	if value == nil || *value == "" {
+		return time.Time{}, true
+	}
+
+	t, err := time.Parse(alerta.TimeLayout, *value)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "dates must be ISO 8601 date format YYYY-MM-DDThh:mm:ss.sssZ")
+		return time.Time{}, false
+	}
+	return t, true
+}
+
+// nullable renders an empty string as null, as Alerta does for
+// unset optional fields.
+func nullable(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+// paginate returns the requested page of items and the pagination
+// fields of the response.
+func paginate[T any](r *http.Request, items []T) ([]T, map[string]interface{}) {
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	if page < 1 {
+		page = 1
+	}
+
+	pageSize, _ := strconv.Atoi(r.URL.Query().Get("page-size"))
+	if pageSize < 1 {
+		pageSize = DefaultPageSize
+	}
+
+	start := min((page-1)*pageSize, len(items))
+	end := min(start+pageSize, len(items))
+
+	return items[start:end], map[string]interface{}{
+		"status":   "ok",
+		"total":    len(items),
+		"page":     page,
+		"pageSize": pageSize,
+		"pages":    (len(items) + pageSize - 1) / pageSize,
+		"more":     end < len(items),
+	}
+}
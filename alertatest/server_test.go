@@ -0,0 +1,216 @@
+package alertatest
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/hmrks/vault-plugin-secrets-alerta/alerta"
+)
+
+const adminKey = "admin-key"
+
+func TestServerAuth(t *testing.T) {
+	srv := NewServer(t, adminKey)
+	ctx := context.Background()
+
+	_, err := srv.Client(t).WithKey("unknown").GetKey(ctx, "1")
+	require.ErrorIs(t, err, alerta.ErrUnauthorized)
+
+	expired := srv.AddKey(alerta.Key{User: "alice", Scopes: []string{"admin"}, ExpireTime: time.Now().Add(-time.Minute)})
+	_, _, err = srv.Client(t).WithKey(expired.Key).ListKeys(ctx, nil)
+	require.ErrorIs(t, err, alerta.ErrUnauthorized)
+	require.ErrorContains(t, err, "expired")
+
+	reader := srv.AddKey(alerta.Key{User: "alice", Scopes: []string{"read"}})
+	client := srv.Client(t).WithKey(reader.Key)
+
+	_, _, err = client.ListKeys(ctx, nil)
+	require.NoError(t, err)
+
+	_, err = client.CreateKey(ctx, alerta.CreateKeyRequest{User: "alice"})
+	require.ErrorIs(t, err, alerta.ErrForbidden)
+
+	require.Equal(t, 2, srv.Key(reader.ID).Count)
+	require.WithinDuration(t, time.Now(), srv.Key(reader.ID).LastUsedTime, time.Second)
+}
+
+func TestServerVersion(t *testing.T) {
+	srv := NewServer(t, adminKey)
+	ctx := context.Background()
+
+	// the manifest needs no valid key
+	client := srv.Client(t).WithKey("unknown")
+	version, err := client.DetectVersion(ctx)
+	require.NoError(t, err)
+	require.Equal(t, DefaultVersion, version)
+
+	srv.SetVersion("8.7.0")
+	version, err = client.DetectVersion(ctx)
+	require.NoError(t, err)
+	require.Equal(t, "8.7.0", version)
+}
+
+func TestInScope(t *testing.T) {
+	for _, tc := range []struct {
+		want   string
+		scopes []string
+		ok     bool
+	}{
+		{"read:alerts", []string{"read:alerts"}, true},
+		{"read:alerts", []string{"read"}, true},
+		{"read:alerts", []string{"write:alerts"}, true},
+		{"read:alerts", []string{"admin"}, true},
+		{"read:alerts", []string{"write:heartbeats"}, false},
+		{"write:keys", []string{"read:keys"}, false},
+		{"admin:users", []string{"write"}, false},
+	} {
+		require.Equal(t, tc.ok, inScope(tc.want, tc.scopes), "%s in %v", tc.want, tc.scopes)
+	}
+}
+
+func TestServerKeys(t *testing.T) {
+	srv := NewServer(t, adminKey)
+	client := srv.Client(t)
+	ctx := context.Background()
+
+	key, err := client.CreateKey(ctx, alerta.CreateKeyRequest{User: "alice", Scopes: []string{"read"}, Customer: "acme"})
+	require.NoError(t, err)
+	require.NotEmpty(t, key.Key)
+	require.True(t, key.ExpireTime.After(time.Now()))
+	require.True(t, key.LastUsedTime.IsZero())
+
+	// keys can be looked up by their secret too
+	got, err := client.GetKey(ctx, key.Key)
+	require.NoError(t, err)
+	require.Equal(t, key.ID, got.ID)
+
+	noCustomer := ""
+	require.NoError(t, client.UpdateKey(ctx, key.ID, alerta.KeyUpdate{Scopes: []string{"write"}, Customer: &noCustomer}))
+	require.Equal(t, []string{"write"}, srv.Key(key.ID).Scopes)
+	require.Empty(t, srv.Key(key.ID).Customer)
+
+	require.NoError(t, client.DeleteKey(ctx, key.ID))
+	require.ErrorIs(t, client.DeleteKey(ctx, key.ID), alerta.ErrNotFound)
+	require.Zero(t, srv.KeyCount())
+}
+
+func TestServerUsers(t *testing.T) {
+	srv := NewServer(t, adminKey)
+	client := srv.Client(t)
+	ctx := context.Background()
+
+	_, err := client.CreateUser(ctx, alerta.UserRequest{Name: "Alice"})
+	require.ErrorContains(t, err, "400")
+
+	user, err := client.CreateUser(ctx, alerta.UserRequest{Name: "Alice", Email: "alice@example.com"})
+	require.NoError(t, err)
+	require.Equal(t, "alice@example.com", user.Login)
+	require.Equal(t, "example.com", user.Domain)
+	require.Equal(t, "active", user.Status)
+
+	_, err = client.CreateUser(ctx, alerta.UserRequest{Name: "Alice again", Email: "alice@example.com"})
+	require.ErrorContains(t, err, "409")
+
+	require.NoError(t, client.UpdateUser(ctx, user.ID, alerta.UserRequest{Status: "inactive"}))
+	got, err := client.GetUser(ctx, user.ID)
+	require.NoError(t, err)
+	require.Equal(t, "inactive", got.Status)
+	require.Equal(t, "Alice", got.Name)
+
+	users, err := client.ListAllUsers(ctx, nil)
+	require.NoError(t, err)
+	require.Len(t, users, 1)
+
+	require.NoError(t, client.DeleteUser(ctx, user.ID))
+	require.Nil(t, srv.User(user.ID))
+}
+
+func TestServerBlackouts(t *testing.T) {
+	srv := NewServer(t, adminKey)
+	client := srv.Client(t)
+	ctx := context.Background()
+
+	_, err := client.CreateBlackout(ctx, alerta.BlackoutRequest{Resource: "db"})
+	require.ErrorContains(t, err, "400")
+
+	blackout, err := client.CreateBlackout(ctx, alerta.BlackoutRequest{Environment: "Production", Resource: "db", Duration: 600})
+	require.NoError(t, err)
+	require.Equal(t, "active", blackout.Status)
+	require.Equal(t, 600, blackout.Duration)
+
+	suppressed, err := client.SendAlert(ctx, alerta.AlertRequest{Resource: "db", Event: "down", Environment: "Production"})
+	require.NoError(t, err)
+	require.NotEmpty(t, suppressed.ID)
+	require.Empty(t, srv.Alerts())
+
+	raised, err := client.SendAlert(ctx, alerta.AlertRequest{Resource: "web", Event: "down", Environment: "Production"})
+	require.NoError(t, err)
+	require.Equal(t, "open", raised.Status)
+	require.Len(t, srv.Alerts(), 1)
+
+	pending, err := client.CreateBlackout(ctx, alerta.BlackoutRequest{Environment: "Production", StartTime: time.Now().Add(time.Hour)})
+	require.NoError(t, err)
+	require.Equal(t, "pending", pending.Status)
+
+	blackouts, err := client.ListAllBlackouts(ctx, nil)
+	require.NoError(t, err)
+	require.Len(t, blackouts, 2)
+
+	require.NoError(t, client.DeleteBlackout(ctx, blackout.ID))
+	_, err = client.GetBlackout(ctx, blackout.ID)
+	require.ErrorIs(t, err, alerta.ErrNotFound)
+}
+
+func TestServerHeartbeats(t *testing.T) {
+	srv := NewServer(t, adminKey)
+	client := srv.Client(t)
+	ctx := context.Background()
+
+	first, err := client.SendHeartbeat(ctx, alerta.HeartbeatRequest{Origin: "vault", Timeout: 60})
+	require.NoError(t, err)
+	require.Equal(t, "ok", first.Status)
+
+	// a heartbeat from the same origin refreshes the first one
+	second, err := client.SendHeartbeat(ctx, alerta.HeartbeatRequest{Origin: "vault", Tags: []string{"a"}, Timeout: 60})
+	require.NoError(t, err)
+	require.Equal(t, first.ID, second.ID)
+	require.Len(t, srv.Heartbeats(), 1)
+
+	require.NoError(t, client.DeleteHeartbeat(ctx, first.ID))
+	require.Empty(t, srv.Heartbeats())
+}
+
+func TestServerFaults(t *testing.T) {
+	srv := NewServer(t, adminKey)
+	client := srv.Client(t)
+	ctx := context.Background()
+
+	srv.Inject(Fault{Method: http.MethodPost, Path: "/key", Skip: 1, Times: 1, Status: http.StatusInternalServerError})
+
+	_, err := client.CreateKey(ctx, alerta.CreateKeyRequest{User: "alice"})
+	require.NoError(t, err)
+
+	_, err = client.CreateKey(ctx, alerta.CreateKeyRequest{User: "alice"})
+	var apiErr *alerta.Error
+	require.ErrorAs(t, err, &apiErr)
+	require.Equal(t, http.StatusInternalServerError, apiErr.StatusCode)
+
+	_, err = client.CreateKey(ctx, alerta.CreateKeyRequest{User: "alice"})
+	require.NoError(t, err)
+
+	srv.Inject(Fault{Status: http.StatusUnauthorized})
+	_, err = client.GetKey(ctx, "key-1")
+	require.ErrorIs(t, err, alerta.ErrUnauthorized)
+
+	srv.ClearFaults()
+	srv.Inject(Fault{Latency: time.Second})
+
+	ctx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	_, err = client.GetKey(ctx, "key-1")
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+}
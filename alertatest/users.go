@@ -0,0 +1,222 @@
+package alertatest
+
+import (
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/hmrks/vault-plugin-secrets-alerta/alerta"
+)
+
+// AddUser stores a user and returns a copy of it. The ID is
+// generated if empty.
+func (s *Server) AddUser(user alerta.User) *alerta.User {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if user.ID == "" {
+		user.ID = s.newID("user")
+	}
+
+	s.users[user.ID] = &user
+	u := user
+	return &u
+}
+
+// User returns a copy of the user with the given ID, or nil.
+func (s *Server) User(id string) *alerta.User {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[id]
+	if !ok {
+		return nil
+	}
+	u := *user
+	return &u
+}
+
+func renderUser(u *alerta.User) map[string]interface{} {
+	return map[string]interface{}{
+		"id":             u.ID,
+		"name":           u.Name,
+		"login":          u.Login,
+		"email":          u.Email,
+		"domain":         u.Domain,
+		"status":         u.Status,
+		"roles":          u.Roles,
+		"attributes":     u.Attributes,
+		"text":           u.Text,
+		"email_verified": u.EmailVerified,
+		"createTime":     formatTime(u.CreateTime),
+		"updateTime":     formatTime(u.UpdateTime),
+		"lastLogin":      formatTime(u.LastLogin),
+	}
+}
+
+// userRequest is the body of a user create or update request.
+type userRequest struct {
+	Name          *string                `json:"name"`
+	Login         *string                `json:"login"`
+	Email         *string                `json:"email"`
+	Password      string                 `json:"password"`
+	Status        *string                `json:"status"`
+	Roles         []string               `json:"roles"`
+	Attributes    map[string]interface{} `json:"attributes"`
+	Text          *string                `json:"text"`
+	EmailVerified *bool                  `json:"email_verified"`
+}
+
+// apply copies the fields set in the request to u.
+func (r *userRequest) apply(u *alerta.User) {
+	set := func(dst *string, src *string) {
+		if src != nil {
+			*dst = *src
+		}
+	}
+
+	set(&u.Name, r.Name)
+	set(&u.Login, r.Login)
+	set(&u.Email, r.Email)
+	set(&u.Status, r.Status)
+	set(&u.Text, r.Text)
+
+	if r.Roles != nil {
+		u.Roles = r.Roles
+	}
+	if r.Attributes != nil {
+		u.Attributes = r.Attributes
+	}
+	if r.EmailVerified != nil {
+		u.EmailVerified = *r.EmailVerified
+	}
+
+	if _, domain, ok := strings.Cut(u.Email, "@"); ok {
+		u.Domain = domain
+	}
+	u.UpdateTime = time.Now().UTC()
+}
+
+// loginTaken reports whether another user has login. s.mu must be held.
+func (s *Server) loginTaken(login, id string) bool {
+	for _, u := range s.users {
+		if u.ID != id && u.Login == login {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Server) handleCreateUser(w http.ResponseWriter, r *http.Request) {
+	var req userRequest
+	if !decode(w, r, &req) {
+		return
+	}
+
+	if req.Name == nil || *req.Name == "" {
+		writeError(w, http.StatusBadRequest, "Missing name")
+		return
+	}
+
+	if req.Email == nil || *req.Email == "" {
+		writeError(w, http.StatusBadRequest, "Missing email")
+		return
+	}
+
+	user := &alerta.User{
+		Login:      *req.Email,
+		Status:     "active",
+		Roles:      []string{"user"},
+		Attributes: map[string]interface{}{},
+		CreateTime: time.Now().UTC(),
+	}
+	req.apply(user)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.loginTaken(user.Login, "") {
+		writeError(w, http.StatusConflict, "Username "+user.Login+" already exists")
+		return
+	}
+
+	user.ID = s.newID("user")
+	s.users[user.ID] = user
+
+	writeJSON(w, http.StatusCreated, map[string]interface{}{
+		"status": "ok",
+		"id":     user.ID,
+		"user":   renderUser(user),
+	})
+}
+
+func (s *Server) handleListUsers(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	var users []map[string]interface{}
+	for _, id := range slices.Sorted(maps.Keys(s.users)) {
+		users = append(users, renderUser(s.users[id]))
+	}
+	s.mu.Unlock()
+
+	users, resp := paginate(r, users)
+	resp["users"] = append([]map[string]interface{}{}, users...)
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (s *Server) handleGetUser(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[r.PathValue("id")]
+	if !ok {
+		writeError(w, http.StatusNotFound, "not found")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"status": "ok",
+		"total":  1,
+		"user":   renderUser(user),
+	})
+}
+
+func (s *Server) handleUpdateUser(w http.ResponseWriter, r *http.Request) {
+	var req userRequest
+	if !decode(w, r, &req) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[r.PathValue("id")]
+	if !ok {
+		writeError(w, http.StatusNotFound, "not found")
+		return
+	}
+
+	if req.Login != nil && s.loginTaken(*req.Login, user.ID) {
+		writeError(w, http.StatusConflict, "Username "+*req.Login+" already exists")
+		return
+	}
+
+	req.apply(user)
+
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+func (s *Server) handleDeleteUser(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id := r.PathValue("id")
+	if _, ok := s.users[id]; !ok {
+		writeError(w, http.StatusNotFound, "not found")
+		return
+	}
+	delete(s.users, id)
+
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
@@ -0,0 +1,219 @@
+package alertasecrets
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/logical"
+
+	"github.com/hmrks/vault-plugin-secrets-alerta/alerta"
+)
+
+const (
+	authKeyStatusStoragePath = "auth-key-status"
+
+	// authKeyCheckInterval is how often the auth key's expiry is
+	// looked up in Alerta.
+	authKeyCheckInterval = time.Hour
+
+	defaultAuthKeyExpiryWarning = 14 * 24 * time.Hour
+	defaultAuthKeyExtension     = 365 * 24 * time.Hour
+)
+
+// alertaAuthKeyExpiryConfig configures how the backend watches the
+// expiry of its own auth key.
+type alertaAuthKeyExpiryConfig struct {
+	// ExpiryWarning is how long before the auth key expires the
+	// backend starts warning.
+	ExpiryWarning time.Duration `json:"expiry_warning"`
+	// AutoExtend extends the auth key by Extension once it is within
+	// ExpiryWarning of expiring, if the key has the admin:keys scope.
+	AutoExtend bool          `json:"auto_extend"`
+	Extension  time.Duration `json:"extension"`
+}
+
+func (c alertaAuthKeyExpiryConfig) expiryWarning() time.Duration {
+	if c.ExpiryWarning <= 0 {
+		return defaultAuthKeyExpiryWarning
+	}
+	return c.ExpiryWarning
+}
+
+func (c alertaAuthKeyExpiryConfig) extension() time.Duration {
+	if c.Extension <= 0 {
+		return defaultAuthKeyExtension
+	}
+	return c.Extension
+}
+
+// alertaAuthKeyStatus records what the last check found out about
+// the auth key.
+type alertaAuthKeyStatus struct {
+	// ExpireTime is zero if the key does not expire or was never
+	// looked up.
+	ExpireTime time.Time `json:"expire_time"`
+	LastCheck  time.Time `json:"last_check"`
+	LastError  string    `json:"last_error,omitempty"`
+}
+
+func getAuthKeyStatus(ctx context.Context, s logical.Storage) (*alertaAuthKeyStatus, error) {
+	entry, err := s.Get(ctx, authKeyStatusStoragePath)
+	if err != nil {
+		return nil, err
+	}
+
+	status := &alertaAuthKeyStatus{}
+	if entry != nil {
+		if err := entry.DecodeJSON(status); err != nil {
+			return nil, fmt.Errorf("error reading auth key status: %w", err)
+		}
+	}
+
+	return status, nil
+}
+
+func setAuthKeyStatus(ctx context.Context, s logical.Storage, status *alertaAuthKeyStatus) error {
+	entry, err := logical.StorageEntryJSON(authKeyStatusStoragePath, status)
+	if err != nil {
+		return err
+	}
+
+	return s.Put(ctx, entry)
+}
+
+// lookupAuthKey returns the client's auth key as Alerta records it.
+// The key is first found by listing keys and matching the secret, and
+// then read by its ID, so that the secret never appears in a request
+// path, where Alerta and proxies would write it to their access logs.
+// It returns an error matching alerta.ErrNotFound if Alerta does not
+// list the key.
+func (c *alertaClient) lookupAuthKey(ctx context.Context) (*alerta.Key, error) {
+	c.authKeyMu.Lock()
+	defer c.authKeyMu.Unlock()
+
+	if c.authKeyID != "" {
+		key, err := c.GetKey(ctx, c.authKeyID)
+		if !errors.Is(err, alerta.ErrNotFound) {
+			return key, err
+		}
+		c.authKeyID = ""
+	}
+
+	keys, err := c.ListAllKeys(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, key := range keys {
+		if key.Key == c.authKey {
+			c.authKeyID = key.ID
+			return &key, nil
+		}
+	}
+
+	return nil, fmt.Errorf("the auth key is not among the keys Alerta lists: %w", alerta.ErrNotFound)
+}
+
+// canExtendKeys reports whether scopes allow updating keys,
+// including the key holding them.
+func canExtendKeys(scopes []string) bool {
+	return slices.ContainsFunc(scopes, func(scope string) bool {
+		return scopeCovers(scope, "admin:keys")
+	})
+}
+
+// checkAuthKeyExpiry looks up when the auth key expires, and warns
+// once it is within the configured threshold, so that it can be
+// replaced before every key issuance starts failing. If configured
+// to, it extends the key instead.
+func (b *alertaBackend) checkAuthKeyExpiry(ctx context.Context, s logical.Storage) error {
+	now := time.Now().UTC()
+	if last := b.lastAuthKeyCheck.Load(); last != 0 && now.Sub(time.Unix(0, last)) < authKeyCheckInterval {
+		return nil
+	}
+	b.lastAuthKeyCheck.Store(now.UnixNano())
+
+	config, err := getConfig(ctx, s)
+	if err != nil {
+		return err
+	}
+
+	if config == nil {
+		return nil
+	}
+
+	status, err := getAuthKeyStatus(ctx, s)
+	if err != nil {
+		return err
+	}
+
+	err = b.updateAuthKeyStatus(ctx, s, config, status, now)
+	status.LastCheck = now
+	status.LastError = ""
+	if err != nil {
+		status.LastError = err.Error()
+	}
+
+	if storeErr := setAuthKeyStatus(ctx, s, status); storeErr != nil {
+		return storeErr
+	}
+
+	// a key Alerta does not list, such as one from its ADMIN_KEYS
+	// setting, cannot be watched, which is not worth failing over
+	if errors.Is(err, alerta.ErrNotFound) {
+		b.Logger().Debug("auth key not found in Alerta, its expiry is not monitored")
+		return nil
+	}
+
+	return err
+}
+
+func (b *alertaBackend) updateAuthKeyStatus(ctx context.Context, s logical.Storage, config *alertaConfig, status *alertaAuthKeyStatus, now time.Time) error {
+	client, err := b.getClient(ctx, s)
+	if err != nil {
+		return fmt.Errorf("error getting client to check the auth key: %w", err)
+	}
+
+	key, err := client.lookupAuthKey(ctx)
+	if err != nil {
+		return fmt.Errorf("error retrieving the auth key: %w", err)
+	}
+
+	status.ExpireTime = key.ExpireTime
+	if key.ExpireTime.IsZero() {
+		return nil
+	}
+
+	remaining := key.ExpireTime.Sub(now)
+	setAuthKeyExpiryGauge(remaining)
+
+	threshold := config.AuthKeyExpiry.expiryWarning()
+	if remaining > threshold {
+		return nil
+	}
+
+	if config.AuthKeyExpiry.AutoExtend && canExtendKeys(key.Scopes) {
+		expireTime := now.Add(config.AuthKeyExpiry.extension())
+		if err := client.UpdateKey(ctx, key.ID, alerta.KeyUpdate{ExpireTime: expireTime}); err != nil {
+			incrAuthKeyCounter("extend_failure")
+			b.Logger().Error("error extending the auth key", "key_id", key.ID, "expire_time", key.ExpireTime, "error", err)
+		} else {
+			incrAuthKeyCounter("extended")
+			b.Logger().Info("extended the auth key", "key_id", key.ID, "expire_time", expireTime)
+			status.ExpireTime = expireTime
+			return nil
+		}
+	}
+
+	incrAuthKeyCounter("expiry_warning")
+	if remaining <= 0 {
+		b.Logger().Error("the auth key has expired, replace it in the configuration", "key_id", key.ID, "expire_time", key.ExpireTime)
+	} else {
+		b.Logger().Warn("the auth key expires soon, replace it in the configuration", "key_id", key.ID, "expire_time", key.ExpireTime, "remaining", remaining.Round(time.Minute))
+	}
+
+	return nil
+}
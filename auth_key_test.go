@@ -0,0 +1,154 @@
+package alertasecrets
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hmrks/vault-plugin-secrets-alerta/alerta"
+	"github.com/hmrks/vault-plugin-secrets-alerta/alertatest"
+)
+
+// testConfigReadData returns the data of a config read.
+func testConfigReadData(t *testing.T, b *alertaBackend, s logical.Storage) map[string]interface{} {
+	t.Helper()
+
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      configStoragePath,
+		Storage:   s,
+	})
+	require.NoError(t, err)
+	require.False(t, resp.IsError())
+	return resp.Data
+}
+
+// testAuthKeyCheck runs the periodic function with the auth key check
+// due.
+func testAuthKeyCheck(t *testing.T, b *alertaBackend, s logical.Storage) error {
+	t.Helper()
+	b.lastAuthKeyCheck.Store(0)
+	return testPeriodic(t, b, s)
+}
+
+func TestAlertaAuthKeyExpiry(t *testing.T) {
+	var logs bytes.Buffer
+	b, s, srv := getTestBackendWithLogger(t, &logs)
+
+	t.Run("Unlisted Key", func(t *testing.T) {
+		// the alertatest admin key is not one of its keys
+		require.NoError(t, testAuthKeyCheck(t, b, s))
+
+		data := testConfigReadData(t, b, s)
+		require.Empty(t, data["auth_key_expire_time"])
+		require.Contains(t, data["auth_key_last_error"], "not among the keys Alerta lists")
+	})
+
+	expireTime := time.Now().Add(30 * 24 * time.Hour).UTC().Truncate(time.Second)
+	admin := srv.AddKey(alerta.Key{User: "admin@example.com", Scopes: []string{"read", "write:keys"}, ExpireTime: expireTime})
+	require.NoError(t, testConfigUpdate(t, b, s, map[string]interface{}{
+		"auth_key": admin.Key,
+	}))
+
+	t.Run("Not Expiring Soon", func(t *testing.T) {
+		sink := useTestMetricsSink(t)
+		logs.Reset()
+
+		// the secret must never be sent in a request path
+		srv.Inject(alertatest.Fault{Path: "/key/" + admin.Key, Status: http.StatusInternalServerError})
+		defer srv.ClearFaults()
+
+		require.NoError(t, testPeriodic(t, b, s))
+
+		data := testConfigReadData(t, b, s)
+		require.Equal(t, expireTime.Format(time.RFC3339), data["auth_key_expire_time"])
+		require.Empty(t, data["auth_key_last_error"])
+		require.NotContains(t, logs.String(), "expires soon")
+
+		interval := sink.Data()[len(sink.Data())-1]
+		require.Contains(t, interval.Gauges, "vault.secrets.alerta.auth_key.expires_in")
+		require.NotContains(t, interval.Counters, "vault.secrets.alerta.auth_key.expiry_warning")
+	})
+
+	t.Run("Check Interval", func(t *testing.T) {
+		srv.Inject(alertatest.Fault{Method: http.MethodGet, Path: "/key/", Status: http.StatusServiceUnavailable})
+		defer srv.ClearFaults()
+
+		require.NoError(t, testPeriodic(t, b, s))
+		require.Error(t, testAuthKeyCheck(t, b, s))
+
+		// the last known expiry is kept
+		data := testConfigReadData(t, b, s)
+		require.Equal(t, expireTime.Format(time.RFC3339), data["auth_key_expire_time"])
+		require.Contains(t, data["auth_key_last_error"], "503")
+	})
+
+	require.NoError(t, testConfigUpdate(t, b, s, map[string]interface{}{
+		"auth_key_expiry_warning": "60d",
+	}))
+
+	t.Run("Expiring Soon", func(t *testing.T) {
+		sink := useTestMetricsSink(t)
+		logs.Reset()
+
+		require.NoError(t, testAuthKeyCheck(t, b, s))
+		require.Contains(t, logs.String(), "the auth key expires soon")
+
+		interval := sink.Data()[len(sink.Data())-1]
+		require.Contains(t, interval.Counters, "vault.secrets.alerta.auth_key.expiry_warning")
+	})
+
+	require.NoError(t, testConfigUpdate(t, b, s, map[string]interface{}{
+		"auth_key_auto_extend": true,
+		"auth_key_extension":   "90d",
+	}))
+
+	t.Run("Extend Without Admin Keys Scope", func(t *testing.T) {
+		logs.Reset()
+
+		require.NoError(t, testAuthKeyCheck(t, b, s))
+		require.Contains(t, logs.String(), "the auth key expires soon")
+		require.Equal(t, expireTime, srv.Key(admin.ID).ExpireTime)
+	})
+
+	t.Run("Extend", func(t *testing.T) {
+		require.NoError(t, srv.Client(t).UpdateKey(context.Background(), admin.ID, alerta.KeyUpdate{Scopes: []string{"admin"}}))
+		sink := useTestMetricsSink(t)
+		logs.Reset()
+
+		require.NoError(t, testAuthKeyCheck(t, b, s))
+		require.NotContains(t, logs.String(), "the auth key expires soon")
+
+		extended := srv.Key(admin.ID).ExpireTime
+		require.WithinDuration(t, time.Now().Add(90*24*time.Hour), extended, time.Minute)
+
+		data := testConfigReadData(t, b, s)
+		require.Equal(t, extended.Format(time.RFC3339), data["auth_key_expire_time"])
+
+		interval := sink.Data()[len(sink.Data())-1]
+		require.Contains(t, interval.Counters, "vault.secrets.alerta.auth_key.extended")
+	})
+
+	t.Run("New Auth Key", func(t *testing.T) {
+		require.NoError(t, testConfigUpdate(t, b, s, map[string]interface{}{
+			"auth_key": auth_key,
+		}))
+
+		data := testConfigReadData(t, b, s)
+		require.Empty(t, data["auth_key_expire_time"])
+		require.Empty(t, data["auth_key_last_error"])
+	})
+}
+
+func TestCanExtendKeys(t *testing.T) {
+	require.True(t, canExtendKeys([]string{"admin"}))
+	require.True(t, canExtendKeys([]string{"read", "admin:keys"}))
+	require.False(t, canExtendKeys([]string{"write:keys"}))
+	require.False(t, canExtendKeys([]string{"admin:users"}))
+	require.False(t, canExtendKeys(nil))
+}
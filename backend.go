@@ -4,6 +4,8 @@ import (
 	"context"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/hashicorp/vault/sdk/framework"
 	"github.com/hashicorp/vault/sdk/logical"
@@ -26,6 +28,23 @@ type alertaBackend struct {
 	lock sync.RWMutex
 	// write a client for alerta
 	client *alertaClient
+	// counterLock serializes updates to the active key counters
+	counterLock sync.Mutex
+	// rateLimitLock serializes updates to the issuance token buckets
+	rateLimitLock sync.Mutex
+	// requestLock serializes decisions on and redemptions of key requests
+	requestLock sync.Mutex
+	// lastIdleCheck is when idle keys were last checked, in Unix nanoseconds
+	lastIdleCheck atomic.Int64
+	// lastExclusiveSweep is when the keys of exclusive roles were last
+	// swept, in Unix nanoseconds
+	lastExclusiveSweep atomic.Int64
+	// lastAuthKeyCheck is when the auth key's expiry was last looked
+	// up, in Unix nanoseconds
+	lastAuthKeyCheck atomic.Int64
+	// lastRequestSweep is when finished key requests were last
+	// deleted, in Unix nanoseconds
+	lastRequestSweep atomic.Int64
 }
 
 // backend defines the target API backend
@@ -44,16 +63,25 @@ func backend() *alertaBackend {
 		},
 		Paths: framework.PathAppend(
 			pathRole(&b),
+			pathRequests(&b),
 			[]*framework.Path{
 				pathConfig(&b),
 				pathKeys(&b),
+				pathRevocationQueue(&b),
+				pathImport(&b),
+				pathKeyInfo(&b),
+				pathRoleDryRun(&b),
+				pathInfo(&b),
 			},
 		),
 		Secrets: []*framework.Secret{
 			b.alertaKey(),
 		},
-		BackendType: logical.TypeLogical,
-		Invalidate:  b.invalidate,
+		BackendType:    logical.TypeLogical,
+		Invalidate:     b.invalidate,
+		Clean:          b.cleanup,
+		PeriodicFunc:   b.periodicFunc,
+		RunningVersion: runningVersion(),
 	}
 	return &b
 }
@@ -61,11 +89,41 @@ func backend() *alertaBackend {
 func (b *alertaBackend) reset() {
 	b.lock.Lock()
 	defer b.lock.Unlock()
+
+	if b.client != nil {
+		client := b.client
+		go func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			if err := client.close(ctx); err != nil {
+				b.Logger().Warn("error shutting down tracing", "error", err)
+			}
+		}()
+	}
+
 	b.client = nil
+
+	// check the expiry of a new auth key on the next periodic tick
+	b.lastAuthKeyCheck.Store(0)
+}
+
+// cleanup stops the client when the backend is unmounted or
+// the plugin is shut down.
+func (b *alertaBackend) cleanup(ctx context.Context) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if b.client != nil {
+		if err := b.client.close(ctx); err != nil {
+			b.Logger().Warn("error shutting down tracing", "error", err)
+		}
+		b.client = nil
+	}
 }
 
 func (b *alertaBackend) invalidate(ctx context.Context, key string) {
 	if key == "config" {
+		b.Logger().Info("configuration changed, resetting client")
 		b.reset()
 	}
 }
@@ -96,7 +154,7 @@ func (b *alertaBackend) getClient(ctx context.Context, s logical.Storage) (*aler
 		}
 	}
 
-	b.client, err = newClient(config)
+	b.client, err = newClient(config, b.Logger().Named("client"))
 	if err != nil {
 		return nil, err
 	}
@@ -2,6 +2,8 @@ package alertasecrets
 
 import (
 	"context"
+	"io"
+	"net/http"
 	"os"
 	"strings"
 	"testing"
@@ -9,6 +11,8 @@ import (
 	"github.com/hashicorp/go-hclog"
 	"github.com/hashicorp/vault/sdk/logical"
 	"github.com/stretchr/testify/require"
+
+	"github.com/hmrks/vault-plugin-secrets-alerta/alertatest"
 )
 
 const (
@@ -136,8 +140,79 @@ func (e *testEnv) CleanupAlertaKeys(t *testing.T) {
 		if err != nil {
 			t.Fatal("fatal getting client")
 		}
-		if _, err := client.deleteKey(e.Context, key_id); err != nil {
+		if err := client.DeleteKey(e.Context, key_id); err != nil {
 			t.Fatalf("unexpected error deleting api key: %s", err)
 		}
 	}
 }
+
+// getTestBackendWithAlerta returns a backend configured
+// against a fresh alertatest.Server.
+func getTestBackendWithAlerta(tb testing.TB) (*alertaBackend, logical.Storage, *alertatest.Server) {
+	tb.Helper()
+
+	b, s := getTestBackend(tb)
+	return b, s, configureTestBackend(tb, b, s)
+}
+
+// configureTestBackend points the backend at a fresh alertatest.Server.
+func configureTestBackend(tb testing.TB, b *alertaBackend, s logical.Storage) *alertatest.Server {
+	tb.Helper()
+
+	srv := alertatest.NewServer(tb, auth_key)
+
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.CreateOperation,
+		Path:      configStoragePath,
+		Storage:   s,
+		Data: map[string]interface{}{
+			"auth_key": auth_key,
+			"api_url":  srv.URL,
+		},
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		tb.Fatalf("error configuring backend: %v %v", err, resp)
+	}
+
+	return srv
+}
+
+// getTestBackendWithLogger returns a backend configured against a fresh
+// alertatest.Server that writes its logs to w.
+func getTestBackendWithLogger(tb testing.TB, w io.Writer) (*alertaBackend, logical.Storage, *alertatest.Server) {
+	tb.Helper()
+
+	config := logical.TestBackendConfig()
+	config.StorageView = new(logical.InmemStorage)
+	config.Logger = hclog.New(&hclog.LoggerOptions{Output: w, Level: hclog.Trace})
+	config.System = logical.TestSystemView()
+
+	raw, err := Factory(context.Background(), config)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	b, s := raw.(*alertaBackend), config.StorageView
+
+	return b, s, configureTestBackend(tb, b, s)
+}
+
+func TestAlertaVersionDetection(t *testing.T) {
+	b, s, srv := getTestBackendWithAlerta(t)
+	srv.Inject(alertatest.Fault{Path: "/management/manifest", Status: http.StatusServiceUnavailable})
+	srv.Inject(alertatest.Fault{Path: "/config", Status: http.StatusServiceUnavailable})
+
+	// requests never wait for the lookup
+	client, err := b.getClient(context.Background(), s)
+	require.NoError(t, err)
+	require.Empty(t, client.ServerVersion())
+
+	require.NoError(t, testPeriodic(t, b, s))
+	require.Empty(t, client.ServerVersion())
+
+	t.Run("Retried By Periodic", func(t *testing.T) {
+		srv.ClearFaults()
+
+		require.NoError(t, testPeriodic(t, b, s))
+		require.Equal(t, alertatest.DefaultVersion, client.ServerVersion())
+	})
+}
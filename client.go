@@ -1,158 +1,199 @@
 package alertasecrets
 
 import (
-	"bytes"
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
-	"io"
 	"net/http"
+	"sync"
 	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/vault/sdk/logical"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+
+	"github.com/hmrks/vault-plugin-secrets-alerta/alerta"
 )
 
+// alertOrigin is the origin of alerts the backend raises in Alerta.
+const alertOrigin = "vault-plugin-secrets-alerta"
+
+// versionDetectTimeout bounds each lookup of the server's version.
+const versionDetectTimeout = 5 * time.Second
+
 // alertaClient creates an object storing
 // the client.
 type alertaClient struct {
-	ApiURL     string
-	AuthKey    string
-	HTTPClient *http.Client
-}
+	*alerta.Client
 
-// newClient creates a new client to access Alerta
-// and exposes it for any secrets or roles to use.
-func newClient(config *alertaConfig) (*alertaClient, error) {
-	if config == nil {
-		return nil, errors.New("client configuration was nil")
-	}
+	// tracerProvider exports spans of Alerta calls,
+	// it is nil when tracing is turned off.
+	tracerProvider *sdktrace.TracerProvider
 
-	if config.ApiURL == "" {
-		return nil, errors.New("client API URL was not defined")
-	}
+	health *clientHealth
 
-	if config.AuthKey == "" {
-		return nil, errors.New("client auth key was not defined")
-	}
+	// authKey is the secret the client authenticates with. Its ID in
+	// Alerta is looked up once and kept in authKeyID.
+	authKey   string
+	authKeyMu sync.Mutex
+	authKeyID string
 
-	return &alertaClient{
-		ApiURL:  config.ApiURL,
-		AuthKey: config.AuthKey,
-		HTTPClient: &http.Client{
-			Timeout: 10 * time.Second,
-		},
-	}, nil
+	logger hclog.Logger
 }
 
-func (c *alertaClient) makeRequest(ctx context.Context, method, endpoint string, body []byte) (*http.Response, error) {
-	req, err := http.NewRequestWithContext(ctx, method, fmt.Sprintf("%s%s", c.ApiURL, endpoint), bytes.NewBuffer(body))
-	if err != nil {
-		return nil, err
-	}
+// clientHealth records the outcome of the client's latest requests.
+type clientHealth struct {
+	mu            sync.Mutex
+	lastSuccess   time.Time
+	lastError     string
+	lastErrorTime time.Time
+}
 
-	// Set headers
-	req.Header.Set("Authorization", fmt.Sprintf("Key %s", c.AuthKey))
-	req.Header.Set("Content-Type", "application/json")
+// record notes the outcome of a request.
+func (h *clientHealth) record(info alerta.RequestInfo) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	end := info.Start.Add(info.Duration)
+	switch {
+	case info.Err != nil:
+		h.lastError = info.Err.Error()
+		h.lastErrorTime = end
+	case requestFailed(info.StatusCode):
+		h.lastError = fmt.Sprintf("%s %s returned status %d (request ID %s)", info.Method, metricEndpoint(info.Endpoint), info.StatusCode, info.RequestID)
+		h.lastErrorTime = end
+	default:
+		h.lastSuccess = end
+	}
+}
 
-	return c.HTTPClient.Do(req)
+// snapshot returns the time of the last successful request, and the
+// last error and its time.
+func (h *clientHealth) snapshot() (time.Time, string, time.Time) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.lastSuccess, h.lastError, h.lastErrorTime
 }
 
-type CreateKeyResponse struct {
-	ID         string `json:"id"`
-	Key        string `json:"key"`
-	ExpireTime string `json:"expireTime"`
+// requestFailed reports whether a status code means the request
+// failed. A missing resource is an answer, not a failure.
+func requestFailed(statusCode int) bool {
+	return statusCode >= http.StatusBadRequest && statusCode != http.StatusNotFound
 }
 
-// should return a key, a key ID and an error
-func (c *alertaClient) createKey(ctx context.Context, user string, scopes []string, text string, expireTime string) (*CreateKeyResponse, error) {
-	requestBody := map[string]interface{}{
-		"user":   user,
-		"scopes": scopes,
-		"text":   text,
+// newClient creates a new client to access Alerta
+// and exposes it for any secrets or roles to use.
+func newClient(config *alertaConfig, logger hclog.Logger) (*alertaClient, error) {
+	if config == nil {
+		return nil, errors.New("client configuration was nil")
 	}
 
-	if expireTime != "" {
-		requestBody["expireTime"] = expireTime
+	if config.ApiURL == "" {
+		return nil, errors.New("client API URL was not defined")
 	}
 
-	jsonBody, err := json.Marshal(requestBody)
-	if err != nil {
-		return nil, err
+	if config.AuthKey == "" {
+		return nil, errors.New("client auth key was not defined")
 	}
 
-	resp, err := c.makeRequest(ctx, "POST", "/key", jsonBody)
-	if err != nil {
-		return nil, err
+	client := &alertaClient{
+		health:  &clientHealth{},
+		authKey: config.AuthKey,
+		logger:  logger,
+	}
+	httpClient := &http.Client{
+		Timeout: alerta.DefaultTimeout,
 	}
 
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusCreated {
-		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	if config.OTLPEndpoint != "" {
+		tp, err := newTracerProvider(context.Background(), config.OTLPEndpoint)
+		if err != nil {
+			return nil, err
+		}
+		client.tracerProvider = tp
+		httpClient.Transport = newTracingTransport(nil, tp)
 	}
 
-	body, err := io.ReadAll(resp.Body)
+	c, err := alerta.NewClient(config.ApiURL, config.AuthKey,
+		alerta.WithHTTPClient(httpClient),
+		alerta.WithObserver(observeRequest(logger, client.health)))
 	if err != nil {
 		return nil, err
 	}
+	client.Client = c
 
-	var responseData struct {
-		Data struct {
-			ID         string `json:"id"`
-			Key        string `json:"key"`
-			ExpireTime string `json:"expireTime"`
-		} `json:"data"`
-		Status string `json:"status"`
-	}
-
-	if err := json.Unmarshal(body, &responseData); err != nil {
-		return nil, err
-	}
+	return client, nil
+}
 
-	if responseData.Status != "ok" {
-		return nil, fmt.Errorf("unexpected status: %s", responseData.Status)
+// detectVersion looks up the server's version while it is unknown.
+func (c *alertaClient) detectVersion(ctx context.Context) {
+	if c.ServerVersion() != "" {
+		return
 	}
 
-	return &CreateKeyResponse{
-		ID:         responseData.Data.ID,
-		Key:        responseData.Data.Key,
-		ExpireTime: responseData.Data.ExpireTime,
-	}, nil
-}
+	ctx, cancel := context.WithTimeout(ctx, versionDetectTimeout)
+	defer cancel()
 
-type DeleteKeyResponse struct {
-	Status string `json:"status"`
+	version, err := c.DetectVersion(ctx)
+	if err != nil {
+		c.logger.Warn("could not detect the Alerta version, will retry on the next periodic run", "error", err)
+		return
+	}
+	c.logger.Debug("detected Alerta version", "version", version)
 }
 
-func (c *alertaClient) deleteKey(ctx context.Context, id string) (*DeleteKeyResponse, error) {
-	resp, err := c.makeRequest(ctx, "DELETE", fmt.Sprintf("/key/%s", id), nil)
+// detectAlertaVersion looks up the version of a configured Alerta
+// while it is unknown, for the info endpoint. It runs from the
+// periodic function rather than on the request path, since a slow or
+// unreachable Alerta would otherwise hold up key issuance.
+func (b *alertaBackend) detectAlertaVersion(ctx context.Context, s logical.Storage) error {
+	config, err := getConfig(ctx, s)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	if config == nil {
+		return nil
 	}
 
-	body, err := io.ReadAll(resp.Body)
+	client, err := b.getClient(ctx, s)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	var responseData struct {
-		Status string `json:"status"`
-	}
+	client.detectVersion(ctx)
+	return nil
+}
 
-	if err := json.Unmarshal(body, &responseData); err != nil {
-		return nil, err
+// observeRequest records the metrics and outcome of every Alerta
+// request and logs the ones that failed.
+func observeRequest(logger hclog.Logger, health *clientHealth) func(alerta.RequestInfo) {
+	return func(info alerta.RequestInfo) {
+		measureRequest(info.Start, info.Method, info.Endpoint, info.StatusCode)
+		health.record(info)
+
+		endpoint := metricEndpoint(info.Endpoint)
+		switch {
+		case info.Err != nil:
+			logger.Error("alerta request failed", "method", info.Method, "endpoint", endpoint, "request_id", info.RequestID, "error", info.Err)
+		case requestFailed(info.StatusCode):
+			logger.Error("alerta request returned an error", "method", info.Method, "endpoint", endpoint, "status", info.StatusCode, "request_id", info.RequestID)
+		default:
+			logger.Trace("alerta request", "method", info.Method, "endpoint", endpoint, "status", info.StatusCode, "duration", info.Duration)
+		}
 	}
+}
 
-	if responseData.Status != "ok" {
-		return nil, fmt.Errorf("unexpected status: %s", responseData.Status)
+// close flushes and stops the client's tracing, if any.
+func (c *alertaClient) close(ctx context.Context) error {
+	if c.tracerProvider == nil {
+		return nil
 	}
+	return c.tracerProvider.Shutdown(ctx)
+}
 
-	return &DeleteKeyResponse{
-		Status: responseData.Status,
-	}, nil
+// probe reads endpoint using key rather than the client's auth key,
+// and returns the response status code.
+func (c *alertaClient) probe(ctx context.Context, key, endpoint string) (int, error) {
+	return c.WithKey(key).Do(ctx, http.MethodGet, endpoint, nil, nil)
 }
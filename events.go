@@ -0,0 +1,34 @@
+package alertasecrets
+
+import (
+	"context"
+	"errors"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+// Event types sent to Vault's event system.
+const (
+	eventKeyIssue           = "alerta/key-issue"
+	eventKeyImport          = "alerta/key-import"
+	eventKeyRevoke          = "alerta/key-revoke"
+	eventKeyRevokeFailed    = "alerta/key-revoke-failed"
+	eventKeyIdleRevoke      = "alerta/key-idle-revoke"
+	eventKeyUnmanaged       = "alerta/key-unmanaged"
+	eventKeyUnmanagedDelete = "alerta/key-unmanaged-delete"
+	eventRoleWrite          = "alerta/role-write"
+	eventRoleDelete         = "alerta/role-delete"
+	eventConfigWrite        = "alerta/config-write"
+	eventConfigDelete       = "alerta/config-delete"
+)
+
+// sendEvent sends an event with the given metadata key/value pairs.
+// Failures are logged rather than returned, so that events never
+// cause the operation they describe to fail.
+func (b *alertaBackend) sendEvent(ctx context.Context, eventType string, metadataPairs ...string) {
+	err := logical.SendEvent(ctx, b, eventType, metadataPairs...)
+	if err != nil && !errors.Is(err, framework.ErrNoEvents) {
+		b.Logger().Warn("error sending event", "event_type", eventType, "error", err)
+	}
+}
@@ -0,0 +1,94 @@
+package alertasecrets
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/require"
+)
+
+// testEventSender records the events sent by the backend.
+type testEventSender struct {
+	mu     sync.Mutex
+	events []testEvent
+}
+
+type testEvent struct {
+	Type     string
+	Metadata map[string]string
+}
+
+func (s *testEventSender) SendEvent(_ context.Context, eventType logical.EventType, event *logical.EventData) error {
+	metadata := map[string]string{}
+	for k, v := range event.Metadata.GetFields() {
+		metadata[k] = v.GetStringValue()
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.events = append(s.events, testEvent{Type: string(eventType), Metadata: metadata})
+	return nil
+}
+
+// ofType returns the recorded events of the given type.
+func (s *testEventSender) ofType(eventType string) []testEvent {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var events []testEvent
+	for _, event := range s.events {
+		if event.Type == eventType {
+			events = append(events, event)
+		}
+	}
+	return events
+}
+
+func TestAlertaEvents(t *testing.T) {
+	events := &testEventSender{}
+
+	config := logical.TestBackendConfig()
+	config.StorageView = new(logical.InmemStorage)
+	config.Logger = hclog.NewNullLogger()
+	config.System = logical.TestSystemView()
+	config.EventsSender = events
+
+	raw, err := Factory(context.Background(), config)
+	require.NoError(t, err)
+	b, s := raw.(*alertaBackend), config.StorageView
+	srv := configureTestBackend(t, b, s)
+
+	require.Len(t, events.ofType(eventConfigWrite), 1)
+
+	_, err = testAlertaRoleCreate(t, b, s, roleName, map[string]interface{}{
+		"user":   user,
+		"scopes": scopes,
+	})
+	require.NoError(t, err)
+	require.Len(t, events.ofType(eventRoleWrite), 1)
+	require.Equal(t, roleName, events.ofType(eventRoleWrite)[0].Metadata["role"])
+
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "keys/" + roleName,
+		Storage:   s,
+		EntityID:  "entity-a",
+	})
+	require.NoError(t, err)
+	id := resp.Data["alerta_api_key_id"].(string)
+
+	issued := events.ofType(eventKeyIssue)
+	require.Len(t, issued, 1)
+	require.Equal(t, id, issued[0].Metadata["key_id"])
+	require.Equal(t, "entity-a", issued[0].Metadata["entity_id"])
+	require.NotContains(t, issued[0].Metadata, "alerta_api_key")
+
+	srv.Close()
+	_, err = testAlertaKeyRevoke(t, b, s, resp)
+	require.NoError(t, err)
+	require.Len(t, events.ofType(eventKeyRevokeFailed), 1)
+	require.Empty(t, events.ofType(eventKeyRevoke))
+}
@@ -0,0 +1,285 @@
+package alertasecrets
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/logical"
+
+	"github.com/hmrks/vault-plugin-secrets-alerta/alerta"
+)
+
+const (
+	unmanagedKeyStoragePrefix = "unmanaged/"
+
+	exclusiveActionReport = "report"
+	exclusiveActionDelete = "delete"
+
+	// exclusiveGracePeriod is how long a key must stay unmanaged before
+	// the sweep acts on it, so that a key being issued concurrently is
+	// never mistaken for one created outside Vault.
+	exclusiveGracePeriod = 5 * time.Minute
+
+	// exclusiveSweepInterval is how often the keys of exclusive roles
+	// are swept. Each sweep pages through every key in Alerta, so it
+	// runs less often than the periodic function.
+	exclusiveSweepInterval = 5 * time.Minute
+)
+
+// alertaUnmanagedKey records a key found for an exclusive role's user
+// and customer that this mount did not issue.
+type alertaUnmanagedKey struct {
+	ID        string    `json:"id"`
+	RoleName  string    `json:"role_name"`
+	Text      string    `json:"text"`
+	FirstSeen time.Time `json:"first_seen"`
+	Reported  bool      `json:"reported"`
+}
+
+func unmanagedKeyStoragePath(role, id string) string {
+	return unmanagedKeyStoragePrefix + role + "/" + id
+}
+
+func getUnmanagedKey(ctx context.Context, s logical.Storage, role, id string) (*alertaUnmanagedKey, error) {
+	entry, err := s.Get(ctx, unmanagedKeyStoragePath(role, id))
+	if err != nil {
+		return nil, err
+	}
+
+	if entry == nil {
+		return nil, nil
+	}
+
+	var key alertaUnmanagedKey
+	if err := entry.DecodeJSON(&key); err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+func setUnmanagedKey(ctx context.Context, s logical.Storage, key *alertaUnmanagedKey) error {
+	entry, err := logical.StorageEntryJSON(unmanagedKeyStoragePath(key.RoleName, key.ID), key)
+	if err != nil {
+		return err
+	}
+
+	return s.Put(ctx, entry)
+}
+
+// managedKeyIDs returns the IDs of every key this mount issued or
+// imported that has not been deleted yet.
+func managedKeyIDs(ctx context.Context, s logical.Storage) (map[string]bool, error) {
+	roles, err := s.List(ctx, issuedKeyStoragePrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	managed := map[string]bool{}
+	for _, role := range roles {
+		ids, err := listIssuedKeys(ctx, s, strings.TrimSuffix(role, "/"))
+		if err != nil {
+			return nil, err
+		}
+		for _, id := range ids {
+			managed[id] = true
+		}
+	}
+
+	return managed, nil
+}
+
+// sweepUnmanagedKeys finds Alerta keys belonging to the user and
+// customer of exclusive roles that this mount did not issue, and
+// reports or deletes them according to the role's exclusive_action.
+func (b *alertaBackend) sweepUnmanagedKeys(ctx context.Context, s logical.Storage) error {
+	now := time.Now().UTC()
+	if last := b.lastExclusiveSweep.Load(); last != 0 && now.Sub(time.Unix(0, last)) < exclusiveSweepInterval {
+		return nil
+	}
+	b.lastExclusiveSweep.Store(now.UnixNano())
+
+	names, err := s.List(ctx, "role/")
+	if err != nil {
+		return err
+	}
+
+	var roles []*alertaRoleEntry
+	for _, name := range names {
+		role, err := b.getRole(ctx, s, name)
+		if err != nil {
+			return fmt.Errorf("error retrieving role %q: %w", name, err)
+		}
+
+		if role != nil && role.Exclusive {
+			role.Name = name
+			roles = append(roles, role)
+		}
+	}
+
+	if err := b.forgetUnmanagedKeys(ctx, s, roles); err != nil {
+		return err
+	}
+
+	if len(roles) == 0 {
+		return nil
+	}
+
+	config, err := getConfig(ctx, s)
+	if err != nil {
+		return err
+	}
+
+	if config == nil {
+		return nil
+	}
+
+	client, err := b.getClient(ctx, s)
+	if err != nil {
+		return fmt.Errorf("error getting client to sweep unmanaged keys: %w", err)
+	}
+
+	keys, err := client.ListAllKeys(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error listing Alerta API keys: %w", err)
+	}
+
+	// read after listing, so that keys issued in between count as managed
+	managed, err := managedKeyIDs(ctx, s)
+	if err != nil {
+		return fmt.Errorf("error listing issued keys: %w", err)
+	}
+
+	var errs []error
+	for _, role := range roles {
+		if err := b.sweepRole(ctx, s, client, role, keys, managed, config.AuthKey); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// sweepRole acts on the unmanaged keys of one exclusive role. The
+// mount's own auth key is never one of them, even if it belongs to the
+// role's user, since deleting it would stop the mount from working.
+func (b *alertaBackend) sweepRole(ctx context.Context, s logical.Storage, client *alertaClient, role *alertaRoleEntry, keys []alerta.Key, managed map[string]bool, authKey string) error {
+	now := time.Now().UTC()
+	unmanaged := map[string]bool{}
+
+	var errs []error
+	for _, key := range keys {
+		if key.User != role.User || key.Customer != role.Customer || managed[key.ID] || key.Key == authKey {
+			continue
+		}
+		unmanaged[key.ID] = true
+
+		record, err := getUnmanagedKey(ctx, s, role.Name, key.ID)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		if record == nil {
+			record = &alertaUnmanagedKey{
+				ID:        key.ID,
+				RoleName:  role.Name,
+				Text:      key.Text,
+				FirstSeen: now,
+			}
+			if err := setUnmanagedKey(ctx, s, record); err != nil {
+				errs = append(errs, err)
+			}
+			continue
+		}
+
+		if now.Sub(record.FirstSeen) < exclusiveGracePeriod {
+			continue
+		}
+
+		switch role.exclusiveAction() {
+		case exclusiveActionDelete:
+			if err := b.deleteKey(ctx, client, key.ID); err != nil && !errors.Is(err, alerta.ErrNotFound) {
+				b.Logger().Error("error deleting unmanaged key", "role", role.Name, "key_id", key.ID, "error", err)
+				errs = append(errs, err)
+				continue
+			}
+
+			b.Logger().Warn("unmanaged key deleted", "role", role.Name, "key_id", key.ID, "user", key.User, "text", key.Text)
+			incrCounter(role.Name, "key", "unmanaged_deleted")
+			b.sendEvent(ctx, eventKeyUnmanagedDelete,
+				"role", role.Name,
+				"key_id", key.ID,
+				"user", key.User)
+
+			delete(unmanaged, key.ID)
+			if err := s.Delete(ctx, unmanagedKeyStoragePath(role.Name, key.ID)); err != nil {
+				errs = append(errs, err)
+			}
+		default:
+			if record.Reported {
+				continue
+			}
+
+			b.Logger().Warn("unmanaged key found", "role", role.Name, "key_id", key.ID, "user", key.User, "text", key.Text)
+			incrCounter(role.Name, "key", "unmanaged_found")
+			b.sendEvent(ctx, eventKeyUnmanaged,
+				"role", role.Name,
+				"key_id", key.ID,
+				"user", key.User)
+
+			record.Reported = true
+			if err := setUnmanagedKey(ctx, s, record); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+
+	// forget keys that were deleted or have since been imported
+	ids, err := s.List(ctx, unmanagedKeyStoragePrefix+role.Name+"/")
+	if err != nil {
+		errs = append(errs, err)
+	}
+	for _, id := range ids {
+		if unmanaged[id] {
+			continue
+		}
+		if err := s.Delete(ctx, unmanagedKeyStoragePath(role.Name, id)); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	setUnmanagedKeysGauge(role.Name, len(unmanaged))
+
+	return errors.Join(errs...)
+}
+
+// forgetUnmanagedKeys removes the records of roles that are no longer
+// exclusive, so that turning exclusive on again starts a fresh grace period.
+func (b *alertaBackend) forgetUnmanagedKeys(ctx context.Context, s logical.Storage, roles []*alertaRoleEntry) error {
+	prefixes, err := s.List(ctx, unmanagedKeyStoragePrefix)
+	if err != nil {
+		return err
+	}
+
+	exclusive := map[string]bool{}
+	for _, role := range roles {
+		exclusive[role.Name] = true
+	}
+
+	for _, prefix := range prefixes {
+		role := strings.TrimSuffix(prefix, "/")
+		if exclusive[role] {
+			continue
+		}
+
+		if err := logical.ClearView(ctx, logical.NewStorageView(s, unmanagedKeyStoragePrefix+prefix)); err != nil {
+			return err
+		}
+		setUnmanagedKeysGauge(role, 0)
+	}
+
+	return nil
+}
@@ -0,0 +1,181 @@
+package alertasecrets
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hmrks/vault-plugin-secrets-alerta/alerta"
+)
+
+// testUnmanagedKeyAged moves the first sighting of an unmanaged key
+// back beyond the grace period.
+func testUnmanagedKeyAged(t *testing.T, s logical.Storage, role, id string) {
+	t.Helper()
+
+	record, err := getUnmanagedKey(context.Background(), s, role, id)
+	require.NoError(t, err)
+	require.NotNil(t, record)
+
+	record.FirstSeen = time.Now().Add(-exclusiveGracePeriod)
+	require.NoError(t, setUnmanagedKey(context.Background(), s, record))
+}
+
+// testExclusiveSweep runs the periodic function with the sweep of
+// exclusive roles due.
+func testExclusiveSweep(t *testing.T, b *alertaBackend, s logical.Storage) error {
+	t.Helper()
+	b.lastExclusiveSweep.Store(0)
+	return testPeriodic(t, b, s)
+}
+
+func TestAlertaExclusiveRole(t *testing.T) {
+	b, s, srv := getTestBackendWithAlerta(t)
+
+	_, err := testAlertaRoleCreate(t, b, s, roleName, map[string]interface{}{
+		"user":      user,
+		"scopes":    scopes,
+		"exclusive": true,
+	})
+	require.NoError(t, err)
+
+	resp, err := testAlertaKeyRead(t, b, s, roleName)
+	require.NoError(t, err)
+	issuedID := resp.Data["alerta_api_key_id"].(string)
+
+	srv.AddKey(alerta.Key{ID: "hand-made", User: user, Scopes: scopes, Text: "made by hand"})
+	srv.AddKey(alerta.Key{ID: "other-user", User: "other@example.com", Scopes: scopes})
+	srv.AddKey(alerta.Key{ID: "other-customer", User: user, Customer: "acme", Scopes: scopes})
+
+	t.Run("Grace Period", func(t *testing.T) {
+		require.NoError(t, testExclusiveSweep(t, b, s))
+
+		record, err := getUnmanagedKey(context.Background(), s, roleName, "hand-made")
+		require.NoError(t, err)
+		require.NotNil(t, record)
+		require.False(t, record.Reported)
+
+		for _, id := range []string{issuedID, "other-user", "other-customer"} {
+			record, err := getUnmanagedKey(context.Background(), s, roleName, id)
+			require.NoError(t, err)
+			require.Nil(t, record, id)
+		}
+	})
+
+	t.Run("Report", func(t *testing.T) {
+		testUnmanagedKeyAged(t, s, roleName, "hand-made")
+		require.NoError(t, testExclusiveSweep(t, b, s))
+
+		record, err := getUnmanagedKey(context.Background(), s, roleName, "hand-made")
+		require.NoError(t, err)
+		require.True(t, record.Reported)
+		require.NotNil(t, srv.Key("hand-made"))
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		_, err := testAlertaRoleUpdate(t, b, s, map[string]interface{}{
+			"exclusive_action": exclusiveActionDelete,
+		})
+		require.NoError(t, err)
+
+		require.NoError(t, testExclusiveSweep(t, b, s))
+		require.Nil(t, srv.Key("hand-made"))
+		require.NotNil(t, srv.Key(issuedID))
+		require.NotNil(t, srv.Key("other-user"))
+		require.NotNil(t, srv.Key("other-customer"))
+
+		record, err := getUnmanagedKey(context.Background(), s, roleName, "hand-made")
+		require.NoError(t, err)
+		require.Nil(t, record)
+	})
+
+	t.Run("Sweep Interval", func(t *testing.T) {
+		srv.AddKey(alerta.Key{ID: "too-early", User: user, Scopes: scopes})
+
+		require.NoError(t, testPeriodic(t, b, s))
+		record, err := getUnmanagedKey(context.Background(), s, roleName, "too-early")
+		require.NoError(t, err)
+		require.Nil(t, record)
+
+		require.NoError(t, srv.Client(t).DeleteKey(context.Background(), "too-early"))
+	})
+
+	t.Run("Not Exclusive", func(t *testing.T) {
+		srv.AddKey(alerta.Key{ID: "hand-made-2", User: user, Scopes: scopes})
+		require.NoError(t, testExclusiveSweep(t, b, s))
+
+		_, err := testAlertaRoleUpdate(t, b, s, map[string]interface{}{
+			"exclusive": false,
+		})
+		require.NoError(t, err)
+
+		require.NoError(t, testExclusiveSweep(t, b, s))
+		ids, err := s.List(context.Background(), unmanagedKeyStoragePrefix)
+		require.NoError(t, err)
+		require.Empty(t, ids)
+		require.NotNil(t, srv.Key("hand-made-2"))
+	})
+}
+
+func TestAlertaExclusiveRoleAuthKey(t *testing.T) {
+	b, s, srv := getTestBackendWithAlerta(t)
+
+	// the auth key belongs to the role's user
+	admin := srv.AddKey(alerta.Key{User: user, Scopes: []string{"admin"}})
+	require.NoError(t, testConfigUpdate(t, b, s, map[string]interface{}{
+		"auth_key": admin.Key,
+	}))
+
+	_, err := testAlertaRoleCreate(t, b, s, roleName, map[string]interface{}{
+		"user":             user,
+		"scopes":           scopes,
+		"exclusive":        true,
+		"exclusive_action": exclusiveActionDelete,
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, testExclusiveSweep(t, b, s))
+	record, err := getUnmanagedKey(context.Background(), s, roleName, admin.ID)
+	require.NoError(t, err)
+	require.Nil(t, record)
+
+	require.NoError(t, testExclusiveSweep(t, b, s))
+	require.NotNil(t, srv.Key(admin.ID))
+
+	_, err = testAlertaKeyRead(t, b, s, roleName)
+	require.NoError(t, err)
+}
+
+func TestAlertaExclusiveActionValidation(t *testing.T) {
+	b, s := getTestBackend(t)
+
+	resp, err := testAlertaRoleCreate(t, b, s, roleName, map[string]interface{}{
+		"user":             user,
+		"scopes":           scopes,
+		"exclusive":        true,
+		"exclusive_action": "ignore",
+	})
+	require.NoError(t, err)
+	require.True(t, resp.IsError())
+}
+
+func TestAlertaClientListKeys(t *testing.T) {
+	b, s, srv := getTestBackendWithAlerta(t)
+
+	for i := range 2*alerta.DefaultPageSize + 1 {
+		srv.AddKey(alerta.Key{ID: fmt.Sprintf("key-%03d", i), User: user, Scopes: scopes})
+	}
+
+	client, err := b.getClient(context.Background(), s)
+	require.NoError(t, err)
+
+	keys, err := client.ListAllKeys(context.Background(), nil)
+	require.NoError(t, err)
+	require.Len(t, keys, 2*alerta.DefaultPageSize+1)
+	require.Equal(t, "key-000", keys[0].ID)
+	require.Equal(t, user, keys[0].User)
+}
@@ -0,0 +1,112 @@
+package alertasecrets
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/logical"
+
+	"github.com/hmrks/vault-plugin-secrets-alerta/alerta"
+)
+
+const (
+	heartbeatStatusStoragePath = "heartbeat-status"
+
+	defaultHeartbeatTimeout = 5 * time.Minute
+)
+
+// alertaHeartbeatConfig configures the heartbeat the backend sends to
+// Alerta on every periodic tick. It is turned off while Origin is empty.
+type alertaHeartbeatConfig struct {
+	Origin      string        `json:"origin"`
+	Tags        []string      `json:"tags"`
+	Timeout     time.Duration `json:"timeout"`
+	Environment string        `json:"environment"`
+}
+
+// alertaHeartbeatStatus records the outcome of the last heartbeats.
+type alertaHeartbeatStatus struct {
+	LastSuccess time.Time `json:"last_success"`
+	LastError   string    `json:"last_error,omitempty"`
+}
+
+func getHeartbeatStatus(ctx context.Context, s logical.Storage) (*alertaHeartbeatStatus, error) {
+	entry, err := s.Get(ctx, heartbeatStatusStoragePath)
+	if err != nil {
+		return nil, err
+	}
+
+	status := &alertaHeartbeatStatus{}
+	if entry != nil {
+		if err := entry.DecodeJSON(status); err != nil {
+			return nil, fmt.Errorf("error reading heartbeat status: %w", err)
+		}
+	}
+
+	return status, nil
+}
+
+func setHeartbeatStatus(ctx context.Context, s logical.Storage, status *alertaHeartbeatStatus) error {
+	entry, err := logical.StorageEntryJSON(heartbeatStatusStoragePath, status)
+	if err != nil {
+		return err
+	}
+
+	return s.Put(ctx, entry)
+}
+
+// sendHeartbeat posts the configured heartbeat to Alerta, so that
+// Alerta raises a stale-heartbeat alert if the backend stops reaching it.
+func (b *alertaBackend) sendHeartbeat(ctx context.Context, s logical.Storage) error {
+	config, err := getConfig(ctx, s)
+	if err != nil {
+		return err
+	}
+
+	if config == nil || config.Heartbeat.Origin == "" {
+		return nil
+	}
+
+	timeout := config.Heartbeat.Timeout
+	if timeout <= 0 {
+		timeout = defaultHeartbeatTimeout
+	}
+
+	status, err := getHeartbeatStatus(ctx, s)
+	if err != nil {
+		return err
+	}
+
+	client, err := b.getClient(ctx, s)
+	if err == nil {
+		heartbeat := alerta.HeartbeatRequest{
+			Origin:  config.Heartbeat.Origin,
+			Tags:    config.Heartbeat.Tags,
+			Timeout: int(timeout.Seconds()),
+		}
+		if config.Heartbeat.Environment != "" {
+			heartbeat.Attributes = map[string]interface{}{
+				"environment": config.Heartbeat.Environment,
+			}
+		}
+		_, err = client.SendHeartbeat(ctx, heartbeat)
+	}
+
+	if err != nil {
+		b.Logger().Warn("error sending heartbeat", "origin", config.Heartbeat.Origin, "error", err)
+		status.LastError = err.Error()
+	} else {
+		status.LastSuccess = time.Now().UTC()
+		status.LastError = ""
+	}
+
+	if storeErr := setHeartbeatStatus(ctx, s, status); storeErr != nil {
+		return storeErr
+	}
+
+	if err != nil {
+		return fmt.Errorf("error sending heartbeat: %w", err)
+	}
+	return nil
+}
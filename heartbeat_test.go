@@ -0,0 +1,65 @@
+package alertasecrets
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/require"
+)
+
+// testPeriodic runs the backend's periodic function once.
+func testPeriodic(t *testing.T, b *alertaBackend, s logical.Storage) error {
+	t.Helper()
+	_, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.RollbackOperation,
+		Storage:   s,
+	})
+	return err
+}
+
+func TestAlertaHeartbeat(t *testing.T) {
+	b, s, srv := getTestBackendWithAlerta(t)
+
+	t.Run("Disabled By Default", func(t *testing.T) {
+		require.NoError(t, testPeriodic(t, b, s))
+		require.Empty(t, srv.Heartbeats())
+	})
+
+	t.Run("Send Heartbeat", func(t *testing.T) {
+		err := testConfigUpdate(t, b, s, map[string]interface{}{
+			"heartbeat_origin":      "vault/alerta",
+			"heartbeat_tags":        "vault,secrets",
+			"heartbeat_environment": "Production",
+		})
+		require.NoError(t, err)
+
+		require.NoError(t, testPeriodic(t, b, s))
+		heartbeats := srv.Heartbeats()
+		require.Len(t, heartbeats, 1)
+		require.Equal(t, "vault/alerta", heartbeats[0].Origin)
+		require.Equal(t, []string{"vault", "secrets"}, heartbeats[0].Tags)
+		require.Equal(t, 300, heartbeats[0].Timeout)
+		require.Equal(t, map[string]interface{}{"environment": "Production"}, heartbeats[0].Attributes)
+
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      configStoragePath,
+			Storage:   s,
+		})
+		require.NoError(t, err)
+		require.NotEmpty(t, resp.Data["heartbeat_last_success"])
+		require.Empty(t, resp.Data["heartbeat_last_error"])
+	})
+
+	t.Run("Failed Heartbeat", func(t *testing.T) {
+		srv.Close()
+
+		require.Error(t, testPeriodic(t, b, s))
+
+		status, err := getHeartbeatStatus(context.Background(), s)
+		require.NoError(t, err)
+		require.False(t, status.LastSuccess.IsZero())
+		require.NotEmpty(t, status.LastError)
+	})
+}
@@ -0,0 +1,136 @@
+package alertasecrets
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/logical"
+
+	"github.com/hmrks/vault-plugin-secrets-alerta/alerta"
+)
+
+// idleCheckInterval is how often outstanding keys are checked for
+// idleness. Each check reads every key of roles with an idle_timeout
+// from Alerta, so it runs less often than the periodic function.
+const idleCheckInterval = 5 * time.Minute
+
+// revokeIdleKeys deletes keys of roles with an idle_timeout that
+// Alerta has not seen used within that timeout.
+func (b *alertaBackend) revokeIdleKeys(ctx context.Context, s logical.Storage) error {
+	now := time.Now().UTC()
+	if last := b.lastIdleCheck.Load(); last != 0 && now.Sub(time.Unix(0, last)) < idleCheckInterval {
+		return nil
+	}
+	b.lastIdleCheck.Store(now.UnixNano())
+
+	names, err := s.List(ctx, "role/")
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, name := range names {
+		role, err := b.getRole(ctx, s, name)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("error retrieving role %q: %w", name, err))
+			continue
+		}
+
+		if role == nil || role.IdleTimeout <= 0 {
+			continue
+		}
+		role.Name = name
+
+		if err := b.revokeIdleRoleKeys(ctx, s, role, now); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func (b *alertaBackend) revokeIdleRoleKeys(ctx context.Context, s logical.Storage, role *alertaRoleEntry, now time.Time) error {
+	ids, err := listIssuedKeys(ctx, s, role.Name)
+	if err != nil {
+		return fmt.Errorf("error listing issued keys: %w", err)
+	}
+
+	if len(ids) == 0 {
+		return nil
+	}
+
+	client, err := b.getClient(ctx, s)
+	if err != nil {
+		return fmt.Errorf("error getting client to check idle keys: %w", err)
+	}
+
+	var errs []error
+	for _, id := range ids {
+		issued, err := getIssuedKey(ctx, s, role.Name, id)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		if issued == nil || !issued.IdleRevokeTime.IsZero() {
+			continue
+		}
+
+		key, err := client.GetKey(ctx, id)
+		if errors.Is(err, alerta.ErrNotFound) {
+			if err := b.removeIssuedKey(ctx, s, role.Name, id); err != nil {
+				errs = append(errs, err)
+			}
+			continue
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("error retrieving Alerta API key %q: %w", id, err))
+			continue
+		}
+
+		lastUsed := keyLastUsed(issued, key)
+
+		if now.Sub(lastUsed) < role.IdleTimeout {
+			continue
+		}
+
+		if err := b.deleteKey(ctx, client, id); err != nil && !errors.Is(err, alerta.ErrNotFound) {
+			b.Logger().Error("error deleting idle key", "role", role.Name, "key_id", id, "error", err)
+			errs = append(errs, err)
+			continue
+		}
+
+		issued.IdleRevokeTime = now
+		if err := setIssuedKey(ctx, s, issued); err != nil {
+			errs = append(errs, fmt.Errorf("error recording idle key: %w", err))
+			continue
+		}
+
+		if err := b.releaseKeys(ctx, s, role.Name, issued.EntityID, 1); err != nil {
+			errs = append(errs, err)
+		}
+
+		b.Logger().Info("idle key deleted", "role", role.Name, "key_id", id, "entity_id", issued.EntityID,
+			"last_used", lastUsed, "count", key.Count)
+		incrCounter(role.Name, "key", "idle_revoked")
+		b.sendEvent(ctx, eventKeyIdleRevoke,
+			"role", role.Name,
+			"key_id", id,
+			"entity_id", issued.EntityID,
+			"last_used", lastUsed.Format(time.RFC3339))
+	}
+
+	return errors.Join(errs...)
+}
+
+// keyLastUsed returns when a key was last used, or when it was issued
+// if that is later, so that new and freshly imported keys get the
+// full idle timeout.
+func keyLastUsed(issued *alertaIssuedKey, key *alerta.Key) time.Time {
+	if key.LastUsedTime.After(issued.IssueTime) {
+		return key.LastUsedTime
+	}
+	return issued.IssueTime
+}
@@ -0,0 +1,113 @@
+package alertasecrets
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hmrks/vault-plugin-secrets-alerta/alerta"
+)
+
+func testAlertaKeyRenew(t *testing.T, b *alertaBackend, s logical.Storage, keyResp *logical.Response) (*logical.Response, error) {
+	t.Helper()
+	return b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.RenewOperation,
+		Storage:   s,
+		Secret:    keyResp.Secret,
+	})
+}
+
+// testIssuedKeyAged moves the issue time of an issued key into the past.
+func testIssuedKeyAged(t *testing.T, s logical.Storage, id string, age time.Duration) {
+	t.Helper()
+
+	issued, err := getIssuedKey(context.Background(), s, roleName, id)
+	require.NoError(t, err)
+	require.NotNil(t, issued)
+
+	issued.IssueTime = time.Now().Add(-age)
+	require.NoError(t, setIssuedKey(context.Background(), s, issued))
+}
+
+func TestAlertaIdleKeys(t *testing.T) {
+	b, s, srv := getTestBackendWithAlerta(t)
+
+	_, err := testAlertaRoleCreate(t, b, s, roleName, map[string]interface{}{
+		"user":            user,
+		"scopes":          scopes,
+		"idle_timeout":    "1h",
+		"max_active_keys": 2,
+	})
+	require.NoError(t, err)
+
+	idleResp, err := testAlertaKeyRead(t, b, s, roleName)
+	require.NoError(t, err)
+	idleID := idleResp.Data["alerta_api_key_id"].(string)
+
+	usedResp, err := testAlertaKeyRead(t, b, s, roleName)
+	require.NoError(t, err)
+	usedID := usedResp.Data["alerta_api_key_id"].(string)
+
+	testIssuedKeyAged(t, s, idleID, 2*time.Hour)
+	testIssuedKeyAged(t, s, usedID, 2*time.Hour)
+	srv.UseKey(usedID, time.Now().Add(-10*time.Minute))
+
+	t.Run("Delete Idle Key", func(t *testing.T) {
+		require.NoError(t, testPeriodic(t, b, s))
+		require.Nil(t, srv.Key(idleID))
+		require.NotNil(t, srv.Key(usedID))
+
+		counter, err := getKeyCounter(context.Background(), s, roleName)
+		require.NoError(t, err)
+		require.Equal(t, 1, counter.Active)
+	})
+
+	t.Run("Renew", func(t *testing.T) {
+		_, err := testAlertaKeyRenew(t, b, s, idleResp)
+		require.ErrorContains(t, err, "idle")
+
+		_, err = testAlertaKeyRenew(t, b, s, usedResp)
+		require.NoError(t, err)
+	})
+
+	t.Run("Revoke Idle Key", func(t *testing.T) {
+		_, err := testAlertaKeyRevoke(t, b, s, idleResp)
+		require.NoError(t, err)
+
+		issued, err := getIssuedKey(context.Background(), s, roleName, idleID)
+		require.NoError(t, err)
+		require.Nil(t, issued)
+
+		// the quota was already released when the key was deleted
+		counter, err := getKeyCounter(context.Background(), s, roleName)
+		require.NoError(t, err)
+		require.Equal(t, 1, counter.Active)
+	})
+
+	t.Run("Check Interval", func(t *testing.T) {
+		srv.UseKey(usedID, time.Now().Add(-2*time.Hour))
+
+		require.NoError(t, testPeriodic(t, b, s))
+		require.NotNil(t, srv.Key(usedID))
+
+		b.lastIdleCheck.Store(0)
+		require.NoError(t, testPeriodic(t, b, s))
+		require.Nil(t, srv.Key(usedID))
+	})
+}
+
+func TestAlertaKeyLastUsed(t *testing.T) {
+	issueTime := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	issued := &alertaIssuedKey{IssueTime: issueTime}
+
+	require.Equal(t, issueTime, keyLastUsed(issued, &alerta.Key{}))
+
+	used := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	require.Equal(t, issueTime, keyLastUsed(issued, &alerta.Key{LastUsedTime: used}))
+
+	used = time.Date(2025, 1, 2, 0, 0, 0, 0, time.UTC)
+	require.Equal(t, used, keyLastUsed(issued, &alerta.Key{LastUsedTime: used}))
+}
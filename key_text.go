@@ -0,0 +1,153 @@
+package alertasecrets
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"text/template"
+	"text/template/parse"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+// defaultKeyTextTemplate reproduces the key text used before
+// text_template was configurable, plus any justification.
+const defaultKeyTextTemplate = `{{.Description}} at {{.Time}}{{if .Justification}} ({{.Justification}}){{end}}`
+
+// alertaKeyTextData is the data available to a role's text_template.
+type alertaKeyTextData struct {
+	Description string
+	RoleName    string
+	MountPath   string
+	EntityID    string
+	EntityName  string
+	DisplayName string
+	RequestID   string
+	// LeasePath is the prefix of the lease ID. Vault only assigns the
+	// full lease ID after the key has been created.
+	LeasePath     string
+	Justification string
+	Time          string
+}
+
+func parseKeyTextTemplate(text string) (*template.Template, error) {
+	if text == "" {
+		text = defaultKeyTextTemplate
+	}
+
+	tmpl, err := template.New("text").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid text_template: %w", err)
+	}
+
+	// catch references to unknown fields before a key is requested
+	if err := tmpl.Execute(io.Discard, alertaKeyTextData{}); err != nil {
+		return nil, fmt.Errorf("invalid text_template: %w", err)
+	}
+	return tmpl, nil
+}
+
+// keyText renders the Alerta key text for a key issued for the
+// role in response to req. A justification the template does not
+// include is appended in parentheses.
+func (b *alertaBackend) keyText(req *logical.Request, role *alertaRoleEntry, justification string) (string, error) {
+	tmpl, err := parseKeyTextTemplate(role.TextTemplate)
+	if err != nil {
+		return "", err
+	}
+
+	data := alertaKeyTextData{
+		Description:   role.Description,
+		RoleName:      role.Name,
+		MountPath:     req.MountPoint,
+		EntityID:      req.EntityID,
+		DisplayName:   req.DisplayName,
+		RequestID:     req.ID,
+		LeasePath:     req.MountPoint + req.Path,
+		Justification: justification,
+		Time:          time.Now().Format(time.RFC3339),
+	}
+
+	if req.EntityID != "" && b.System() != nil {
+		entity, err := b.System().EntityInfo(req.EntityID)
+		if err != nil {
+			return "", fmt.Errorf("error looking up entity: %w", err)
+		}
+		if entity != nil {
+			data.EntityName = entity.Name
+		}
+	}
+
+	var text strings.Builder
+	if err := tmpl.Execute(&text, data); err != nil {
+		return "", fmt.Errorf("error rendering text_template: %w", err)
+	}
+
+	// the justification is always shown in Alerta, even if a custom
+	// text_template leaves it out
+	if justification != "" && !templateUsesField(tmpl, "Justification") {
+		fmt.Fprintf(&text, " (%s)", justification)
+	}
+
+	return text.String(), nil
+}
+
+// templateUsesField reports whether any template in tmpl refers to
+// the named field of its data, as .Name or $.Name.
+func templateUsesField(tmpl *template.Template, name string) bool {
+	for _, t := range tmpl.Templates() {
+		if t.Tree != nil && nodeUsesField(t.Tree.Root, name) {
+			return true
+		}
+	}
+	return false
+}
+
+func nodeUsesField(node parse.Node, name string) bool {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return false
+		}
+		for _, child := range n.Nodes {
+			if nodeUsesField(child, name) {
+				return true
+			}
+		}
+	case *parse.ActionNode:
+		return nodeUsesField(n.Pipe, name)
+	case *parse.IfNode:
+		return nodeUsesField(&n.BranchNode, name)
+	case *parse.RangeNode:
+		return nodeUsesField(&n.BranchNode, name)
+	case *parse.WithNode:
+		return nodeUsesField(&n.BranchNode, name)
+	case *parse.BranchNode:
+		return nodeUsesField(n.Pipe, name) || nodeUsesField(n.List, name) || nodeUsesField(n.ElseList, name)
+	case *parse.TemplateNode:
+		return nodeUsesField(n.Pipe, name)
+	case *parse.PipeNode:
+		if n == nil {
+			return false
+		}
+		for _, cmd := range n.Cmds {
+			if nodeUsesField(cmd, name) {
+				return true
+			}
+		}
+	case *parse.CommandNode:
+		for _, arg := range n.Args {
+			if nodeUsesField(arg, name) {
+				return true
+			}
+		}
+	case *parse.ChainNode:
+		return nodeUsesField(n.Node, name)
+	case *parse.FieldNode:
+		return len(n.Ident) > 0 && n.Ident[0] == name
+	case *parse.VariableNode:
+		return len(n.Ident) > 1 && n.Ident[0] == "$" && n.Ident[1] == name
+	}
+	return false
+}
@@ -0,0 +1,121 @@
+package alertasecrets
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseKeyTextTemplate(t *testing.T) {
+	_, err := parseKeyTextTemplate("")
+	require.NoError(t, err)
+
+	_, err = parseKeyTextTemplate("{{.RoleName}} for {{.EntityName}}")
+	require.NoError(t, err)
+
+	_, err = parseKeyTextTemplate("{{.RoleName")
+	require.Error(t, err)
+
+	_, err = parseKeyTextTemplate("{{.Unknown}}")
+	require.Error(t, err)
+}
+
+func TestAlertaKeyText(t *testing.T) {
+	b, s, srv := getTestBackendWithAlerta(t)
+
+	_, err := testAlertaRoleCreate(t, b, s, roleName, map[string]interface{}{
+		"user":          user,
+		"scopes":        scopes,
+		"text_template": "{{.RoleName}} via {{.MountPath}} for {{.DisplayName}} ({{.RequestID}})",
+	})
+	require.NoError(t, err)
+
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		ID:          "req-1",
+		Operation:   logical.ReadOperation,
+		Path:        "keys/" + roleName,
+		MountPoint:  "alerta/",
+		DisplayName: "token-ci",
+		Storage:     s,
+	})
+	require.NoError(t, err)
+
+	key := srv.Key(resp.Data["alerta_api_key_id"].(string))
+	require.Equal(t, "testrole via alerta/ for token-ci (req-1)", key.Text)
+
+	// a justification the template leaves out is appended
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		ID:          "req-2",
+		Operation:   logical.UpdateOperation,
+		Path:        "keys/" + roleName,
+		MountPoint:  "alerta/",
+		DisplayName: "token-ci",
+		Storage:     s,
+		Data:        map[string]interface{}{"justification": "INC-1234"},
+	})
+	require.NoError(t, err)
+
+	key = srv.Key(resp.Data["alerta_api_key_id"].(string))
+	require.Equal(t, "testrole via alerta/ for token-ci (req-2) (INC-1234)", key.Text)
+
+	// even if the rendered text happens to contain it already
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		ID:          "req-3",
+		Operation:   logical.UpdateOperation,
+		Path:        "keys/" + roleName,
+		MountPoint:  "alerta/",
+		DisplayName: "token-ci",
+		Storage:     s,
+		Data:        map[string]interface{}{"justification": "ci"},
+	})
+	require.NoError(t, err)
+
+	key = srv.Key(resp.Data["alerta_api_key_id"].(string))
+	require.Equal(t, "testrole via alerta/ for token-ci (req-3) (ci)", key.Text)
+
+	_, err = testAlertaRoleCreate(t, b, s, roleName, map[string]interface{}{
+		"user":          user,
+		"scopes":        scopes,
+		"text_template": "{{.Justification}}: {{.RoleName}}",
+	})
+	require.NoError(t, err)
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "keys/" + roleName,
+		Storage:   s,
+		Data:      map[string]interface{}{"justification": "INC-1234"},
+	})
+	require.NoError(t, err)
+
+	key = srv.Key(resp.Data["alerta_api_key_id"].(string))
+	require.Equal(t, "INC-1234: testrole", key.Text)
+
+	resp, err = testAlertaRoleCreate(t, b, s, roleName, map[string]interface{}{
+		"user":          user,
+		"scopes":        scopes,
+		"text_template": "{{.LeaseID}}",
+	})
+	require.NoError(t, err)
+	require.True(t, resp.IsError())
+}
+
+func TestTemplateUsesField(t *testing.T) {
+	for text, uses := range map[string]bool{
+		"{{.RoleName}}":                                             false,
+		"{{.Justification}}":                                        true,
+		"{{$.Justification}}":                                       true,
+		"{{if .Justification}}x{{end}}":                             true,
+		"{{if .RoleName}}{{else}}{{.Justification}}{{end}}":         true,
+		"{{with .RoleName}}{{$.Justification}}{{end}}":              true,
+		"{{printf \"%s\" .Justification | print}}":                  true,
+		`{{define "j"}}{{.Justification}}{{end}}{{template "j" .}}`: true,
+		"Justification":                                             false,
+	} {
+		tmpl, err := parseKeyTextTemplate(text)
+		require.NoError(t, err, text)
+		require.Equal(t, uses, templateUsesField(tmpl, "Justification"), text)
+	}
+}
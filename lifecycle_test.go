@@ -0,0 +1,114 @@
+package alertasecrets
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/hmrks/vault-plugin-secrets-alerta/alerta"
+	"github.com/hmrks/vault-plugin-secrets-alerta/alertatest"
+)
+
+// TestAlertaKeyLifecycle issues, uses, renews and revokes a key
+// against alertatest, with faults injected along the way.
+func TestAlertaKeyLifecycle(t *testing.T) {
+	b, s, srv := getTestBackendWithAlerta(t)
+
+	_, err := testAlertaRoleCreate(t, b, s, roleName, map[string]interface{}{
+		"user":    user,
+		"scopes":  []string{"read:alerts", "write:alerts"},
+		"ttl":     "1h",
+		"max_ttl": "24h",
+	})
+	require.NoError(t, err)
+
+	resp, err := testAlertaKeyRead(t, b, s, roleName)
+	require.NoError(t, err)
+	id := resp.Data["alerta_api_key_id"].(string)
+	secret := resp.Data["alerta_api_key"].(string)
+
+	t.Run("Issue", func(t *testing.T) {
+		key := srv.Key(id)
+		require.NotNil(t, key)
+		require.Equal(t, secret, key.Key)
+		require.Equal(t, user, key.User)
+		require.Equal(t, []string{"read:alerts", "write:alerts"}, key.Scopes)
+		require.WithinDuration(t, time.Now().Add(24*time.Hour), key.ExpireTime, time.Minute)
+		require.Equal(t, time.Hour, resp.Secret.TTL)
+	})
+
+	t.Run("Use", func(t *testing.T) {
+		client := srv.Client(t).WithKey(secret)
+
+		status, err := client.Do(context.Background(), http.MethodGet, "/alerts/count", nil, nil)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, status)
+
+		_, err = client.CreateKey(context.Background(), alerta.CreateKeyRequest{User: user})
+		require.ErrorIs(t, err, alerta.ErrForbidden)
+
+		require.Equal(t, 2, srv.Key(id).Count)
+	})
+
+	t.Run("Renew", func(t *testing.T) {
+		renewResp, err := testAlertaKeyRenew(t, b, s, resp)
+		require.NoError(t, err)
+		require.Equal(t, time.Hour, renewResp.Secret.TTL)
+		require.NotNil(t, srv.Key(id))
+	})
+
+	t.Run("Issue With Rejected Auth Key", func(t *testing.T) {
+		srv.Inject(alertatest.Fault{Method: http.MethodPost, Path: "/key", Times: 1, Status: http.StatusUnauthorized})
+
+		_, err := testAlertaKeyRead(t, b, s, roleName)
+		require.ErrorContains(t, err, "401")
+
+		// the fault is spent, so the next request goes through
+		other, err := testAlertaKeyRead(t, b, s, roleName)
+		require.NoError(t, err)
+		_, err = testAlertaKeyRevoke(t, b, s, other)
+		require.NoError(t, err)
+		srv.ClearFaults()
+	})
+
+	t.Run("Revoke Slowly", func(t *testing.T) {
+		srv.Inject(alertatest.Fault{Method: http.MethodDelete, Path: "/key/", Times: 1, Latency: 50 * time.Millisecond})
+
+		start := time.Now()
+		_, err := testAlertaKeyRevoke(t, b, s, resp)
+		require.NoError(t, err)
+		require.GreaterOrEqual(t, time.Since(start), 50*time.Millisecond)
+		srv.ClearFaults()
+
+		require.Nil(t, srv.Key(id))
+
+		issued, err := getIssuedKey(context.Background(), s, roleName, id)
+		require.NoError(t, err)
+		require.Nil(t, issued)
+
+		// the deleted key no longer authenticates
+		_, err = srv.Client(t).WithKey(secret).Do(context.Background(), http.MethodGet, "/alerts/count", nil, nil)
+		require.ErrorIs(t, err, alerta.ErrUnauthorized)
+	})
+
+	t.Run("Revoke During Outage", func(t *testing.T) {
+		resp, err := testAlertaKeyRead(t, b, s, roleName)
+		require.NoError(t, err)
+		id := resp.Data["alerta_api_key_id"].(string)
+
+		srv.Inject(alertatest.Fault{Path: "/key/", Status: http.StatusServiceUnavailable})
+
+		_, err = testAlertaKeyRevoke(t, b, s, resp)
+		require.NoError(t, err)
+		require.NotNil(t, srv.Key(id))
+
+		srv.ClearFaults()
+		testRevocationDue(t, s, id)
+		require.NoError(t, testPeriodic(t, b, s))
+		require.Nil(t, srv.Key(id))
+		require.Zero(t, srv.KeyCount())
+	})
+}
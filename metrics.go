@@ -0,0 +1,85 @@
+package alertasecrets
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	metrics "github.com/armon/go-metrics"
+)
+
+// metricsPrefix is prepended to every metric the backend emits.
+var metricsPrefix = []string{"secrets", "alerta"}
+
+func metricName(name ...string) []string {
+	return append(append([]string{}, metricsPrefix...), name...)
+}
+
+func roleLabel(role string) []metrics.Label {
+	return []metrics.Label{{Name: "role", Value: role}}
+}
+
+func incrCounter(role string, name ...string) {
+	metrics.IncrCounterWithLabels(metricName(name...), 1, roleLabel(role))
+}
+
+func measureSince(start time.Time, role string, name ...string) {
+	metrics.MeasureSinceWithLabels(metricName(name...), start, roleLabel(role))
+}
+
+// setActiveKeysGauge reports the number of active keys of a role.
+func setActiveKeysGauge(role string, active int) {
+	metrics.SetGaugeWithLabels(metricName("keys", "active"), float32(active), roleLabel(role))
+}
+
+// setUnmanagedKeysGauge reports the number of keys for an exclusive
+// role's user and customer that this mount did not issue.
+func setUnmanagedKeysGauge(role string, unmanaged int) {
+	metrics.SetGaugeWithLabels(metricName("keys", "unmanaged"), float32(unmanaged), roleLabel(role))
+}
+
+// setRevocationQueueGauge reports the number of keys waiting to be deleted.
+func setRevocationQueueGauge(queued int) {
+	metrics.SetGauge(metricName("revocation_queue", "size"), float32(queued))
+}
+
+// setAuthKeyExpiryGauge reports how long the auth key has left
+// before it expires.
+func setAuthKeyExpiryGauge(remaining time.Duration) {
+	metrics.SetGauge(metricName("auth_key", "expires_in"), float32(remaining.Seconds()))
+}
+
+// incrAuthKeyCounter counts events of the auth key, which has no role.
+func incrAuthKeyCounter(name string) {
+	metrics.IncrCounter(metricName("auth_key", name), 1)
+}
+
+// measureRequest records the latency of an Alerta API call. A status
+// of 0 means no response was received.
+func measureRequest(start time.Time, method, endpoint string, status int) {
+	statusLabel := "error"
+	if status != 0 {
+		statusLabel = strconv.Itoa(status)
+	}
+
+	metrics.MeasureSinceWithLabels(metricName("api", "request"), start, []metrics.Label{
+		{Name: "method", Value: method},
+		{Name: "endpoint", Value: metricEndpoint(endpoint)},
+		{Name: "status", Value: statusLabel},
+	})
+}
+
+// metricEndpoint strips key IDs and query strings from an endpoint, so
+// that each Alerta endpoint is reported under a single label value.
+func metricEndpoint(endpoint string) string {
+	endpoint, _, _ = strings.Cut(endpoint, "?")
+
+	segments := strings.Split(endpoint, "/")
+	for i := 1; i < len(segments); i++ {
+		if segments[i-1] == "key" && segments[i] != "" {
+			segments[i] = ":id"
+		}
+	}
+
+	return strings.Join(segments, "/")
+}
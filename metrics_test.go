@@ -0,0 +1,63 @@
+package alertasecrets
+
+import (
+	"testing"
+	"time"
+
+	metrics "github.com/armon/go-metrics"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetricEndpoint(t *testing.T) {
+	require.Equal(t, "/key", metricEndpoint("/key"))
+	require.Equal(t, "/key/:id", metricEndpoint("/key/1234-abcd"))
+	require.Equal(t, "/keys", metricEndpoint("/keys?page=2"))
+}
+
+// useTestMetricsSink routes metrics to an in-memory sink for the
+// duration of the test.
+func useTestMetricsSink(t *testing.T) *metrics.InmemSink {
+	t.Helper()
+
+	conf := metrics.DefaultConfig("vault")
+	conf.EnableHostname = false
+	conf.EnableRuntimeMetrics = false
+
+	sink := metrics.NewInmemSink(time.Hour, time.Hour)
+	_, err := metrics.NewGlobal(conf, sink)
+	require.NoError(t, err)
+
+	t.Cleanup(func() {
+		_, _ = metrics.NewGlobal(conf, &metrics.BlackholeSink{})
+	})
+
+	return sink
+}
+
+func TestAlertaKeyMetrics(t *testing.T) {
+	sink := useTestMetricsSink(t)
+	b, s, _ := getTestBackendWithAlerta(t)
+
+	_, err := testAlertaRoleCreate(t, b, s, roleName, map[string]interface{}{
+		"user":   user,
+		"scopes": scopes,
+	})
+	require.NoError(t, err)
+
+	resp, err := testAlertaKeyRead(t, b, s, roleName)
+	require.NoError(t, err)
+
+	_, err = testAlertaKeyRevoke(t, b, s, resp)
+	require.NoError(t, err)
+
+	intervals := sink.Data()
+	require.NotEmpty(t, intervals)
+	interval := intervals[len(intervals)-1]
+
+	require.Contains(t, interval.Counters, "vault.secrets.alerta.key.issued;role="+roleName)
+	require.Contains(t, interval.Counters, "vault.secrets.alerta.key.revoked;role="+roleName)
+	require.Contains(t, interval.Samples, "vault.secrets.alerta.key.issue;role="+roleName)
+	require.Contains(t, interval.Samples, "vault.secrets.alerta.api.request;method=POST;endpoint=/key;status=201")
+	require.Contains(t, interval.Samples, "vault.secrets.alerta.api.request;method=DELETE;endpoint=/key/:id;status=200")
+	require.Equal(t, float32(0), interval.Gauges["vault.secrets.alerta.keys.active;role="+roleName].Value)
+}
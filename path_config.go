@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/hashicorp/vault/sdk/framework"
 	"github.com/hashicorp/vault/sdk/logical"
@@ -16,8 +17,16 @@ const (
 // alertaConfig includes the minimum configuration
 // required to instantiate a new Alerta client.
 type alertaConfig struct {
-	ApiURL  string `json:"api_url"`
-	AuthKey string `json:"auth_key"`
+	ApiURL           string   `json:"api_url"`
+	AuthKey          string   `json:"auth_key"`
+	AllowedScopes    []string `json:"allowed_scopes"`
+	DeniedScopes     []string `json:"denied_scopes"`
+	AllowAdminScopes bool     `json:"allow_admin_scopes"`
+	OTLPEndpoint     string   `json:"otlp_endpoint"`
+
+	Heartbeat       alertaHeartbeatConfig       `json:"heartbeat"`
+	RevocationAlert alertaRevocationAlertConfig `json:"revocation_alert"`
+	AuthKeyExpiry   alertaAuthKeyExpiryConfig   `json:"auth_key_expiry"`
 }
 
 func getConfig(ctx context.Context, s logical.Storage) (*alertaConfig, error) {
@@ -67,6 +76,111 @@ func pathConfig(b *alertaBackend) *framework.Path {
 					Sensitive: false,
 				},
 			},
+			"allowed_scopes": {
+				Type:        framework.TypeCommaStringSlice,
+				Description: "Scopes that roles may grant. A scope also allows its narrower forms and the weaker scopes it grants, so 'write' allows 'write:alerts' and 'read:alerts'. If empty, any scope not denied is allowed.",
+				DisplayAttrs: &framework.DisplayAttributes{
+					Name: "Allowed Scopes",
+				},
+			},
+			"denied_scopes": {
+				Type:        framework.TypeCommaStringSlice,
+				Description: "Scopes that roles may never grant, including any broader or stronger scope that would grant them, since admin grants write, which grants read.",
+				DisplayAttrs: &framework.DisplayAttributes{
+					Name: "Denied Scopes",
+				},
+			},
+			"allow_admin_scopes": {
+				Type:        framework.TypeBool,
+				Description: "Allow roles to grant 'admin' or 'admin:*' scopes. Defaults to false.",
+				DisplayAttrs: &framework.DisplayAttributes{
+					Name: "Allow Admin Scopes",
+				},
+			},
+			"otlp_endpoint": {
+				Type:        framework.TypeString,
+				Description: "OTLP/HTTP endpoint to export traces of Alerta API calls to, for example http://collector:4318. If empty, tracing is turned off.",
+				DisplayAttrs: &framework.DisplayAttributes{
+					Name: "OTLP Endpoint",
+				},
+			},
+			"heartbeat_origin": {
+				Type:        framework.TypeString,
+				Description: "Origin of the heartbeat sent to Alerta on every periodic tick. If empty, no heartbeat is sent.",
+				DisplayAttrs: &framework.DisplayAttributes{
+					Name: "Heartbeat Origin",
+				},
+			},
+			"heartbeat_tags": {
+				Type:        framework.TypeCommaStringSlice,
+				Description: "Tags of the heartbeat sent to Alerta.",
+				DisplayAttrs: &framework.DisplayAttributes{
+					Name: "Heartbeat Tags",
+				},
+			},
+			"heartbeat_timeout": {
+				Type:        framework.TypeDurationSecond,
+				Description: "Time after the last heartbeat at which Alerta considers it stale. If not set or set to 0, defaults to 5m.",
+				DisplayAttrs: &framework.DisplayAttributes{
+					Name: "Heartbeat Timeout",
+				},
+			},
+			"heartbeat_environment": {
+				Type:        framework.TypeString,
+				Description: "Environment attribute of the heartbeat sent to Alerta.",
+				DisplayAttrs: &framework.DisplayAttributes{
+					Name: "Heartbeat Environment",
+				},
+			},
+			"revocation_alert_threshold": {
+				Type:        framework.TypeInt,
+				Description: "Number of failed attempts to delete a revoked key after which an alert is raised in Alerta. If not set or set to 0, defaults to 5.",
+				DisplayAttrs: &framework.DisplayAttributes{
+					Name: "Revocation Alert Threshold",
+				},
+			},
+			"revocation_alert_severity": {
+				Type:        framework.TypeString,
+				Description: "Severity of the alert raised when a revoked key cannot be deleted. Defaults to major.",
+				DisplayAttrs: &framework.DisplayAttributes{
+					Name: "Revocation Alert Severity",
+				},
+			},
+			"revocation_alert_environment": {
+				Type:        framework.TypeString,
+				Description: "Environment of the alert raised when a revoked key cannot be deleted. Defaults to Production.",
+				DisplayAttrs: &framework.DisplayAttributes{
+					Name: "Revocation Alert Environment",
+				},
+			},
+			"revocation_alert_resource": {
+				Type:        framework.TypeString,
+				Description: "Resource of the alert raised when a revoked key cannot be deleted. Defaults to vault-plugin-secrets-alerta.",
+				DisplayAttrs: &framework.DisplayAttributes{
+					Name: "Revocation Alert Resource",
+				},
+			},
+			"auth_key_expiry_warning": {
+				Type:        framework.TypeDurationSecond,
+				Description: "Time before the auth key expires at which the backend starts logging warnings and emitting metrics. If not set or set to 0, defaults to 14 days.",
+				DisplayAttrs: &framework.DisplayAttributes{
+					Name: "Auth Key Expiry Warning",
+				},
+			},
+			"auth_key_auto_extend": {
+				Type:        framework.TypeBool,
+				Description: "Extend the auth key once it is within auth_key_expiry_warning of expiring. Requires the auth key to have the 'admin:keys' scope. Defaults to false.",
+				DisplayAttrs: &framework.DisplayAttributes{
+					Name: "Auth Key Auto Extend",
+				},
+			},
+			"auth_key_extension": {
+				Type:        framework.TypeDurationSecond,
+				Description: "Time from now the auth key is extended to when auth_key_auto_extend is set. If not set or set to 0, defaults to 365 days.",
+				DisplayAttrs: &framework.DisplayAttributes{
+					Name: "Auth Key Extension",
+				},
+			},
 		},
 		Operations: map[logical.Operation]framework.OperationHandler{
 			logical.ReadOperation: &framework.PathOperation{
@@ -105,9 +219,51 @@ func (b *alertaBackend) pathConfigRead(ctx context.Context, req *logical.Request
 		return nil, err
 	}
 
+	heartbeatStatus, err := getHeartbeatStatus(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	heartbeatLastSuccess := ""
+	if !heartbeatStatus.LastSuccess.IsZero() {
+		heartbeatLastSuccess = heartbeatStatus.LastSuccess.Format(time.RFC3339)
+	}
+
+	authKeyStatus, err := getAuthKeyStatus(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	authKeyExpireTime := ""
+	if !authKeyStatus.ExpireTime.IsZero() {
+		authKeyExpireTime = authKeyStatus.ExpireTime.Format(time.RFC3339)
+	}
+
 	return &logical.Response{
 		Data: map[string]interface{}{
-			"api_url": config.ApiURL,
+			"api_url":            config.ApiURL,
+			"allowed_scopes":     config.AllowedScopes,
+			"denied_scopes":      config.DeniedScopes,
+			"allow_admin_scopes": config.AllowAdminScopes,
+			"otlp_endpoint":      config.OTLPEndpoint,
+
+			"heartbeat_origin":       config.Heartbeat.Origin,
+			"heartbeat_tags":         config.Heartbeat.Tags,
+			"heartbeat_timeout":      config.Heartbeat.Timeout.Seconds(),
+			"heartbeat_environment":  config.Heartbeat.Environment,
+			"heartbeat_last_success": heartbeatLastSuccess,
+			"heartbeat_last_error":   heartbeatStatus.LastError,
+
+			"revocation_alert_threshold":   config.RevocationAlert.Threshold,
+			"revocation_alert_severity":    config.RevocationAlert.Severity,
+			"revocation_alert_environment": config.RevocationAlert.Environment,
+			"revocation_alert_resource":    config.RevocationAlert.Resource,
+
+			"auth_key_expire_time":    authKeyExpireTime,
+			"auth_key_last_error":     authKeyStatus.LastError,
+			"auth_key_expiry_warning": config.AuthKeyExpiry.ExpiryWarning.Seconds(),
+			"auth_key_auto_extend":    config.AuthKeyExpiry.AutoExtend,
+			"auth_key_extension":      config.AuthKeyExpiry.Extension.Seconds(),
 		},
 	}, nil
 }
@@ -134,12 +290,77 @@ func (b *alertaBackend) pathConfigWrite(ctx context.Context, req *logical.Reques
 		return nil, errors.New("api_url is required")
 	}
 
+	authKeyChanged := false
 	if auth_key, ok := data.GetOk("auth_key"); ok {
+		authKeyChanged = auth_key.(string) != config.AuthKey
 		config.AuthKey = auth_key.(string)
 	} else if !ok && createOperation {
 		return nil, errors.New("auth_key is required")
 	}
 
+	if allowedScopes, ok := data.GetOk("allowed_scopes"); ok {
+		config.AllowedScopes = allowedScopes.([]string)
+	}
+
+	if deniedScopes, ok := data.GetOk("denied_scopes"); ok {
+		config.DeniedScopes = deniedScopes.([]string)
+	}
+
+	if allowAdminScopes, ok := data.GetOk("allow_admin_scopes"); ok {
+		config.AllowAdminScopes = allowAdminScopes.(bool)
+	}
+
+	if otlpEndpoint, ok := data.GetOk("otlp_endpoint"); ok {
+		config.OTLPEndpoint = otlpEndpoint.(string)
+	}
+
+	if heartbeatOrigin, ok := data.GetOk("heartbeat_origin"); ok {
+		config.Heartbeat.Origin = heartbeatOrigin.(string)
+	}
+
+	if heartbeatTags, ok := data.GetOk("heartbeat_tags"); ok {
+		config.Heartbeat.Tags = heartbeatTags.([]string)
+	}
+
+	if heartbeatTimeout, ok := data.GetOk("heartbeat_timeout"); ok {
+		config.Heartbeat.Timeout = time.Duration(heartbeatTimeout.(int)) * time.Second
+	}
+
+	if heartbeatEnvironment, ok := data.GetOk("heartbeat_environment"); ok {
+		config.Heartbeat.Environment = heartbeatEnvironment.(string)
+	}
+
+	if threshold, ok := data.GetOk("revocation_alert_threshold"); ok {
+		if threshold.(int) < 0 {
+			return logical.ErrorResponse("revocation_alert_threshold must not be negative"), nil
+		}
+		config.RevocationAlert.Threshold = threshold.(int)
+	}
+
+	if severity, ok := data.GetOk("revocation_alert_severity"); ok {
+		config.RevocationAlert.Severity = severity.(string)
+	}
+
+	if environment, ok := data.GetOk("revocation_alert_environment"); ok {
+		config.RevocationAlert.Environment = environment.(string)
+	}
+
+	if resource, ok := data.GetOk("revocation_alert_resource"); ok {
+		config.RevocationAlert.Resource = resource.(string)
+	}
+
+	if expiryWarning, ok := data.GetOk("auth_key_expiry_warning"); ok {
+		config.AuthKeyExpiry.ExpiryWarning = time.Duration(expiryWarning.(int)) * time.Second
+	}
+
+	if autoExtend, ok := data.GetOk("auth_key_auto_extend"); ok {
+		config.AuthKeyExpiry.AutoExtend = autoExtend.(bool)
+	}
+
+	if extension, ok := data.GetOk("auth_key_extension"); ok {
+		config.AuthKeyExpiry.Extension = time.Duration(extension.(int)) * time.Second
+	}
+
 	entry, err := logical.StorageEntryJSON(configStoragePath, config)
 	if err != nil {
 		return nil, err
@@ -149,6 +370,23 @@ func (b *alertaBackend) pathConfigWrite(ctx context.Context, req *logical.Reques
 		return nil, err
 	}
 
+	// what was found out about the previous auth key does not apply
+	// to the new one
+	if authKeyChanged {
+		if err := req.Storage.Delete(ctx, authKeyStatusStoragePath); err != nil {
+			return nil, err
+		}
+	}
+
+	b.Logger().Info("configuration written", "api_url", config.ApiURL, "allowed_scopes", config.AllowedScopes,
+		"denied_scopes", config.DeniedScopes, "allow_admin_scopes", config.AllowAdminScopes,
+		"otlp_endpoint", config.OTLPEndpoint, "heartbeat_origin", config.Heartbeat.Origin)
+	b.sendEvent(ctx, eventConfigWrite,
+		logical.EventMetadataOperation, string(req.Operation),
+		logical.EventMetadataDataPath, req.Path,
+		logical.EventMetadataModified, "true",
+		"entity_id", req.EntityID)
+
 	// reset the client so the next invocation will pick up the new configuration
 	b.reset()
 
@@ -160,6 +398,12 @@ func (b *alertaBackend) pathConfigDelete(ctx context.Context, req *logical.Reque
 	err := req.Storage.Delete(ctx, configStoragePath)
 
 	if err == nil {
+		b.Logger().Info("configuration deleted")
+		b.sendEvent(ctx, eventConfigDelete,
+			logical.EventMetadataOperation, string(req.Operation),
+			logical.EventMetadataDataPath, req.Path,
+			logical.EventMetadataModified, "true",
+			"entity_id", req.EntityID)
 		b.reset()
 	}
 
@@ -176,4 +420,21 @@ API keys issued to applications working with Alerta.
 
 You must provide the URL for the Alerta API and an
 authentication key to authorize requests.
+
+The allowed_scopes and denied_scopes options set a ceiling
+on the scopes any role may grant. Admin scopes are refused
+unless allow_admin_scopes is enabled.
+
+If heartbeat_origin is set, the backend sends a heartbeat
+to Alerta about once a minute, so that Alerta raises an
+alert when the integration stops working.
+
+Keys that cannot be deleted when their lease is revoked are
+queued and retried. Once the revocation_alert_threshold is
+reached, the backend raises an alert in Alerta.
+
+The backend looks up the expiry of the auth key about once an
+hour, and warns once it is within auth_key_expiry_warning. With
+auth_key_auto_extend, it extends the key instead, if the key has
+the admin:keys scope.
 `
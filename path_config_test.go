@@ -3,6 +3,7 @@ package alertasecrets
 import (
 	"context"
 	"fmt"
+	"reflect"
 	"testing"
 
 	"github.com/hashicorp/vault/sdk/logical"
@@ -26,20 +27,69 @@ func TestConfig(t *testing.T) {
 		assert.NoError(t, err)
 
 		err = testConfigRead(t, b, reqStorage, map[string]interface{}{
-			"api_url": api_url,
+			"api_url":            api_url,
+			"allowed_scopes":     []string(nil),
+			"denied_scopes":      []string(nil),
+			"allow_admin_scopes": false,
+			"otlp_endpoint":      "",
+
+			"heartbeat_origin":       "",
+			"heartbeat_tags":         []string(nil),
+			"heartbeat_timeout":      float64(0),
+			"heartbeat_environment":  "",
+			"heartbeat_last_success": "",
+			"heartbeat_last_error":   "",
+
+			"revocation_alert_threshold":   0,
+			"revocation_alert_severity":    "",
+			"revocation_alert_environment": "",
+			"revocation_alert_resource":    "",
+
+			"auth_key_expire_time":    "",
+			"auth_key_last_error":     "",
+			"auth_key_expiry_warning": float64(0),
+			"auth_key_auto_extend":    false,
+			"auth_key_extension":      float64(0),
 		})
 
 		assert.NoError(t, err)
 
 		err = testConfigUpdate(t, b, reqStorage, map[string]interface{}{
-			"auth_key": "87654321",
-			"api_url":  "http://alerta:8080",
+			"auth_key":       "87654321",
+			"api_url":        "http://alerta:8080",
+			"allowed_scopes": "read,write:alerts",
+			"denied_scopes":  "write:alerts:blackouts",
+
+			"auth_key_expiry_warning": "72h",
+			"auth_key_auto_extend":    true,
 		})
 
 		assert.NoError(t, err)
 
 		err = testConfigRead(t, b, reqStorage, map[string]interface{}{
-			"api_url": "http://alerta:8080",
+			"api_url":            "http://alerta:8080",
+			"allowed_scopes":     []string{"read", "write:alerts"},
+			"denied_scopes":      []string{"write:alerts:blackouts"},
+			"allow_admin_scopes": false,
+			"otlp_endpoint":      "",
+
+			"heartbeat_origin":       "",
+			"heartbeat_tags":         []string(nil),
+			"heartbeat_timeout":      float64(0),
+			"heartbeat_environment":  "",
+			"heartbeat_last_success": "",
+			"heartbeat_last_error":   "",
+
+			"revocation_alert_threshold":   0,
+			"revocation_alert_severity":    "",
+			"revocation_alert_environment": "",
+			"revocation_alert_resource":    "",
+
+			"auth_key_expire_time":    "",
+			"auth_key_last_error":     "",
+			"auth_key_expiry_warning": float64(72 * 60 * 60),
+			"auth_key_auto_extend":    true,
+			"auth_key_extension":      float64(0),
 		})
 
 		assert.NoError(t, err)
@@ -131,7 +181,7 @@ func testConfigRead(t *testing.T, b logical.Backend, s logical.Storage, expected
 
 		if !ok {
 			return fmt.Errorf(`expected data["%s"] = %v but was not included in read output"`, k, expectedV)
-		} else if expectedV != actualV {
+		} else if !reflect.DeepEqual(expectedV, actualV) {
 			return fmt.Errorf(`expected data["%s"] = %v, instead got %v"`, k, expectedV, actualV)
 		}
 	}
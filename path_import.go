@@ -0,0 +1,218 @@
+package alertasecrets
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+
+	"github.com/hmrks/vault-plugin-secrets-alerta/alerta"
+)
+
+// pathImport extends the Vault API with an `/import` endpoint that
+// brings a key created outside Vault under lease management.
+func pathImport(b *alertaBackend) *framework.Path {
+	return &framework.Path{
+		Pattern: "import",
+		Fields: map[string]*framework.FieldSchema{
+			"key_id": {
+				Type:        framework.TypeString,
+				Description: "ID of the existing Alerta API key",
+				Required:    true,
+			},
+			"role": {
+				Type:        framework.TypeLowerCaseString,
+				Description: "Name of the role whose lease settings and quotas apply to the key",
+				Required:    true,
+			},
+			"justification": {
+				Type:        framework.TypeString,
+				Description: "Reason for importing the key, such as a change ticket. Required if the role sets require_justification.",
+			},
+		},
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.pathImportWrite,
+		},
+		HelpSynopsis:    pathImportHelpSyn,
+		HelpDescription: pathImportHelpDesc,
+	}
+}
+
+// pathImportWrite checks that an existing key fits a role and returns
+// a lease for it, so that Vault deletes the key once the lease ends.
+func (b *alertaBackend) pathImportWrite(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	keyID := d.Get("key_id").(string)
+	if keyID == "" {
+		return logical.ErrorResponse("key_id is required"), nil
+	}
+
+	roleName := d.Get("role").(string)
+	if roleName == "" {
+		return logical.ErrorResponse("role is required"), nil
+	}
+
+	roleEntry, err := b.getRole(ctx, req.Storage, roleName)
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving role: %w", err)
+	}
+
+	if roleEntry == nil {
+		return logical.ErrorResponse("role %q not found", roleName), nil
+	}
+
+	roleEntry.Name = roleName
+
+	justification := d.Get("justification").(string)
+	if err := roleEntry.checkJustification(justification); err != nil {
+		return logical.ErrorResponse(err.Error()), nil
+	}
+
+	issued, err := findIssuedKey(ctx, req.Storage, keyID)
+	if err != nil {
+		return nil, err
+	}
+
+	if issued != nil {
+		return logical.ErrorResponse("key %q is already managed by role %q", keyID, issued.RoleName), nil
+	}
+
+	config, err := getConfig(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := b.getClient(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := client.GetKey(ctx, keyID)
+	if errors.Is(err, alerta.ErrNotFound) {
+		return logical.ErrorResponse("key %q not found in Alerta", keyID), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving Alerta API key: %w", err)
+	}
+
+	// the lease would delete the mount's own auth key when it ends
+	authKey, err := client.lookupAuthKey(ctx)
+	if err != nil && !errors.Is(err, alerta.ErrNotFound) {
+		return nil, fmt.Errorf("error retrieving the auth key: %w", err)
+	}
+	if authKey != nil && authKey.ID == key.ID {
+		return logical.ErrorResponse("key %q is the auth key of this mount and cannot be imported", keyID), nil
+	}
+
+	if err := roleEntry.checkImportedKey(key); err != nil {
+		return logical.ErrorResponse(err.Error()), nil
+	}
+
+	if err := config.checkScopes(key.Scopes); err != nil {
+		return logical.ErrorResponse(err.Error()), nil
+	}
+
+	expireTime := key.ExpireTime
+	if !expireTime.IsZero() && expireTime.Before(time.Now()) {
+		return logical.ErrorResponse("key %q expired at %s", keyID, expireTime.Format(time.RFC3339)), nil
+	}
+
+	if err := b.reserveKeys(ctx, req.Storage, roleEntry, req.EntityID, 1); err != nil {
+		return nil, err
+	}
+
+	if err := setIssuedKey(ctx, req.Storage, &alertaIssuedKey{
+		ID:            key.ID,
+		RoleName:      roleName,
+		EntityID:      req.EntityID,
+		Justification: justification,
+		IssueTime:     time.Now().UTC(),
+		ExpireTime:    expireTime,
+		LeasePath:     req.MountPoint + req.Path,
+		Imported:      true,
+	}); err != nil {
+		if relErr := b.releaseKeys(ctx, req.Storage, roleName, req.EntityID, 1); relErr != nil {
+			return nil, fmt.Errorf("error recording imported key: %w (releasing quota also failed: %v)", err, relErr)
+		}
+		return nil, fmt.Errorf("error recording imported key: %w", err)
+	}
+
+	b.Logger().Info("key imported", "role", roleName, "key_id", key.ID, "entity_id", req.EntityID,
+		"request_id", req.ID, "expire_time", expireTime)
+	incrCounter(roleName, "key", "imported")
+	b.sendEvent(ctx, eventKeyImport,
+		logical.EventMetadataOperation, string(req.Operation),
+		logical.EventMetadataDataPath, req.Path,
+		"role", roleName,
+		"key_id", key.ID,
+		"entity_id", req.EntityID,
+		"display_name", req.DisplayName)
+
+	// The key itself is not returned: whoever imports a key
+	// already holds it, and this path must not reveal it.
+	resp := b.Secret(alertaKeyType).Response(map[string]interface{}{
+		"alerta_api_key_id": key.ID,
+		"alerta_endpoint":   client.Endpoint(),
+		"expire_time":       expireTime,
+		"role_name":         roleName,
+	}, map[string]interface{}{
+		"alerta_api_key_id": key.ID,
+		"role_name":         roleName,
+		"justification":     justification,
+		"imported":          true,
+	})
+
+	if roleEntry.TTL > 0 {
+		resp.Secret.TTL = roleEntry.TTL
+	}
+
+	if roleEntry.MaxTTL > 0 {
+		resp.Secret.MaxTTL = roleEntry.MaxTTL
+	}
+
+	return resp, nil
+}
+
+// checkImportedKey verifies that a key created outside Vault grants
+// no more than the role would have issued.
+func (r *alertaRoleEntry) checkImportedKey(key *alerta.Key) error {
+	if key.User != r.User {
+		return fmt.Errorf("key %q belongs to user %q, but role %q issues keys for %q", key.ID, key.User, r.Name, r.User)
+	}
+
+	if key.Customer != r.Customer {
+		return fmt.Errorf("key %q belongs to customer %q, but role %q issues keys for %q", key.ID, key.Customer, r.Name, r.Customer)
+	}
+
+	for _, scope := range key.Scopes {
+		covered := false
+		for _, parent := range r.Scopes {
+			if scopeGrants(parent, scope) {
+				covered = true
+				break
+			}
+		}
+		if !covered {
+			return fmt.Errorf("key %q has scope %q, which role %q does not grant", key.ID, scope, r.Name)
+		}
+	}
+
+	return nil
+}
+
+const pathImportHelpSyn = `
+Adopt an existing Alerta API key into Vault lease management.
+`
+
+const pathImportHelpDesc = `
+This path takes the ID of an Alerta API key created outside
+Vault and returns a lease for it under the given role. When
+the lease is revoked or expires, the key is deleted from
+Alerta, as for keys the role issued itself.
+
+The key must belong to the role's user and customer, and its
+scopes must be within the role's scopes. The key itself is
+not returned.
+`
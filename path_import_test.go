@@ -0,0 +1,138 @@
+package alertasecrets
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hmrks/vault-plugin-secrets-alerta/alerta"
+)
+
+func testAlertaKeyImport(t *testing.T, b *alertaBackend, s logical.Storage, d map[string]interface{}) (*logical.Response, error) {
+	t.Helper()
+	return b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "import",
+		Data:      d,
+		Storage:   s,
+		EntityID:  "entity-a",
+	})
+}
+
+func TestAlertaKeyImport(t *testing.T) {
+	b, s, srv := getTestBackendWithAlerta(t)
+
+	_, err := testAlertaRoleCreate(t, b, s, roleName, map[string]interface{}{
+		"user":            user,
+		"scopes":          "write",
+		"ttl":             "1h",
+		"max_active_keys": 1,
+	})
+	require.NoError(t, err)
+
+	expireTime := time.Now().Add(24 * time.Hour).UTC().Truncate(time.Second)
+	srv.AddKey(alerta.Key{ID: "legacy-1", User: user, Scopes: []string{"write:alerts"}, ExpireTime: expireTime})
+	srv.AddKey(alerta.Key{ID: "legacy-2", User: user, Scopes: []string{"write:alerts"}, ExpireTime: expireTime})
+	srv.AddKey(alerta.Key{ID: "other-user", User: "other@example.com", Scopes: []string{"write:alerts"}})
+	srv.AddKey(alerta.Key{ID: "broader", User: user, Scopes: []string{"admin"}})
+	srv.AddKey(alerta.Key{ID: "expired", User: user, Scopes: []string{"write"}, ExpireTime: time.Now().Add(-time.Hour).UTC()})
+
+	t.Run("Rejects Keys Outside Role", func(t *testing.T) {
+		for id, msg := range map[string]string{
+			"missing":    "not found",
+			"other-user": "belongs to user",
+			"broader":    "does not grant",
+			"expired":    "expired",
+		} {
+			resp, err := testAlertaKeyImport(t, b, s, map[string]interface{}{
+				"key_id": id,
+				"role":   roleName,
+			})
+			require.NoError(t, err)
+			require.True(t, resp.IsError(), id)
+			require.Contains(t, resp.Error().Error(), msg, id)
+		}
+	})
+
+	var resp *logical.Response
+	t.Run("Import Key", func(t *testing.T) {
+		resp, err = testAlertaKeyImport(t, b, s, map[string]interface{}{
+			"key_id": "legacy-1",
+			"role":   roleName,
+		})
+		require.NoError(t, err)
+		require.False(t, resp.IsError())
+		require.NotNil(t, resp.Secret)
+		require.Equal(t, time.Hour, resp.Secret.TTL)
+		require.Equal(t, "legacy-1", resp.Data["alerta_api_key_id"])
+		require.Equal(t, expireTime, resp.Data["expire_time"])
+		require.NotContains(t, resp.Data, "alerta_api_key")
+
+		issued, err := getIssuedKey(context.Background(), s, roleName, "legacy-1")
+		require.NoError(t, err)
+		require.NotNil(t, issued)
+		require.True(t, issued.Imported)
+		require.Equal(t, "entity-a", issued.EntityID)
+	})
+
+	t.Run("Rejects Managed Key", func(t *testing.T) {
+		resp, err := testAlertaKeyImport(t, b, s, map[string]interface{}{
+			"key_id": "legacy-1",
+			"role":   roleName,
+		})
+		require.NoError(t, err)
+		require.True(t, resp.IsError())
+		require.Contains(t, resp.Error().Error(), "already managed")
+	})
+
+	t.Run("Counts Towards Quota", func(t *testing.T) {
+		_, err := testAlertaKeyImport(t, b, s, map[string]interface{}{
+			"key_id": "legacy-2",
+			"role":   roleName,
+		})
+		require.ErrorContains(t, err, "max_active_keys")
+		require.NotNil(t, srv.Key("legacy-2"))
+	})
+
+	t.Run("Revoke Deletes Key", func(t *testing.T) {
+		_, err := testAlertaKeyRevoke(t, b, s, resp)
+		require.NoError(t, err)
+		require.Nil(t, srv.Key("legacy-1"))
+
+		issued, err := getIssuedKey(context.Background(), s, roleName, "legacy-1")
+		require.NoError(t, err)
+		require.Nil(t, issued)
+	})
+}
+
+func TestAlertaKeyImportAuthKey(t *testing.T) {
+	b, s, srv := getTestBackendWithAlerta(t)
+
+	admin := srv.AddKey(alerta.Key{User: user, Scopes: []string{"admin"}})
+	require.NoError(t, testConfigUpdate(t, b, s, map[string]interface{}{
+		"auth_key":           admin.Key,
+		"allow_admin_scopes": true,
+	}))
+
+	_, err := testAlertaRoleCreate(t, b, s, roleName, map[string]interface{}{
+		"user":   user,
+		"scopes": "admin",
+	})
+	require.NoError(t, err)
+
+	resp, err := testAlertaKeyImport(t, b, s, map[string]interface{}{
+		"key_id": admin.ID,
+		"role":   roleName,
+	})
+	require.NoError(t, err)
+	require.True(t, resp.IsError())
+	require.Contains(t, resp.Error().Error(), "auth key of this mount")
+
+	issued, err := findIssuedKey(context.Background(), s, admin.ID)
+	require.NoError(t, err)
+	require.Nil(t, issued)
+	require.NotNil(t, srv.Key(admin.ID))
+}
@@ -0,0 +1,89 @@
+package alertasecrets
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+// pathInfo extends the Vault API with an `/info` endpoint that shows
+// which plugin build is running and the state of its Alerta client.
+func pathInfo(b *alertaBackend) *framework.Path {
+	return &framework.Path{
+		Pattern: "info",
+		Operations: map[logical.Operation]framework.OperationHandler{
+			logical.ReadOperation: &framework.PathOperation{
+				Callback: b.pathInfoRead,
+			},
+		},
+		HelpSynopsis:    pathInfoHelpSyn,
+		HelpDescription: pathInfoHelpDesc,
+	}
+}
+
+// pathInfoRead reports the plugin build and, once the mount is
+// configured, the Alerta server, the auth key and the client's
+// health. Alerta being unreachable is reported rather than failing
+// the read, since that is when the details are needed most.
+func (b *alertaBackend) pathInfoRead(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	respData := map[string]interface{}{
+		"version": runningVersion(),
+		"commit":  commit,
+	}
+	resp := &logical.Response{Data: respData}
+
+	config, err := getConfig(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	if config == nil {
+		resp.AddWarning("the backend is not configured")
+		return resp, nil
+	}
+
+	client, err := b.getClient(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	respData["alerta_url"] = client.Endpoint()
+	respData["alerta_version"] = client.ServerVersion()
+
+	key, err := client.lookupAuthKey(ctx)
+	if err != nil {
+		resp.AddWarning(fmt.Sprintf("error retrieving the auth key from Alerta: %s", err))
+	} else {
+		respData["auth_key_scopes"] = key.Scopes
+		if !key.ExpireTime.IsZero() {
+			respData["auth_key_expire_time"] = key.ExpireTime
+		}
+	}
+
+	lastSuccess, lastError, lastErrorTime := client.health.snapshot()
+	if !lastSuccess.IsZero() {
+		respData["last_success_time"] = lastSuccess
+	}
+	if lastError != "" {
+		respData["last_error"] = lastError
+		respData["last_error_time"] = lastErrorTime
+	}
+
+	return resp, nil
+}
+
+const pathInfoHelpSyn = `
+Show the plugin build and the state of its connection to Alerta.
+`
+
+const pathInfoHelpDesc = `
+This path returns the version and Git commit of the running plugin
+build. Once the mount is configured, it also returns the Alerta URL
+and server version, the scopes and expiry of the auth key, and
+when the last request to Alerta succeeded and the last error.
+
+If Alerta cannot be reached, the build details and client health
+are still returned, with a warning.
+`
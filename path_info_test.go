@@ -0,0 +1,91 @@
+package alertasecrets
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hmrks/vault-plugin-secrets-alerta/alerta"
+	"github.com/hmrks/vault-plugin-secrets-alerta/alertatest"
+)
+
+func testInfoRead(t *testing.T, b *alertaBackend, s logical.Storage) (*logical.Response, error) {
+	t.Helper()
+	return b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "info",
+		Storage:   s,
+	})
+}
+
+func TestAlertaInfo(t *testing.T) {
+	t.Run("Not Configured", func(t *testing.T) {
+		b, s := getTestBackend(t)
+
+		resp, err := testInfoRead(t, b, s)
+		require.NoError(t, err)
+		require.False(t, resp.IsError())
+		require.Equal(t, runningVersion(), resp.Data["version"])
+		require.Equal(t, commit, resp.Data["commit"])
+		require.NotContains(t, resp.Data, "alerta_version")
+		require.NotEmpty(t, resp.Warnings)
+	})
+
+	b, s, srv := getTestBackendWithAlerta(t)
+	srv.SetVersion("8.7.0")
+
+	expireTime := time.Now().Add(30 * 24 * time.Hour).UTC().Truncate(time.Millisecond)
+	admin := srv.AddKey(alerta.Key{User: "admin@example.com", Scopes: []string{"admin", "write:keys"}, ExpireTime: expireTime})
+	require.NoError(t, testConfigUpdate(t, b, s, map[string]interface{}{
+		"auth_key": admin.Key,
+	}))
+
+	// the version is looked up by the periodic function
+	require.NoError(t, testPeriodic(t, b, s))
+
+	t.Run("Configured", func(t *testing.T) {
+		// the secret must never be sent in a request path
+		srv.Inject(alertatest.Fault{Path: "/key/" + admin.Key, Status: http.StatusInternalServerError})
+		defer srv.ClearFaults()
+
+		resp, err := testInfoRead(t, b, s)
+		require.NoError(t, err)
+		require.Empty(t, resp.Warnings)
+
+		require.Equal(t, srv.URL, resp.Data["alerta_url"])
+		require.Equal(t, "8.7.0", resp.Data["alerta_version"])
+		require.Equal(t, []string{"admin", "write:keys"}, resp.Data["auth_key_scopes"])
+		require.Equal(t, expireTime, resp.Data["auth_key_expire_time"])
+		require.WithinDuration(t, time.Now(), resp.Data["last_success_time"].(time.Time), time.Second)
+		require.NotContains(t, resp.Data, "last_error")
+	})
+
+	t.Run("Alerta Unavailable", func(t *testing.T) {
+		srv.Inject(alertatest.Fault{Path: "/key/", Status: http.StatusServiceUnavailable})
+		defer srv.ClearFaults()
+
+		resp, err := testInfoRead(t, b, s)
+		require.NoError(t, err)
+		require.False(t, resp.IsError())
+		require.NotEmpty(t, resp.Warnings)
+		require.NotContains(t, resp.Data, "auth_key_scopes")
+		require.Contains(t, resp.Data["last_error"], "503")
+		require.WithinDuration(t, time.Now(), resp.Data["last_error_time"].(time.Time), time.Second)
+		require.Contains(t, resp.Data, "last_success_time")
+	})
+}
+
+func TestRunningVersion(t *testing.T) {
+	b, _ := getTestBackend(t)
+	require.Equal(t, runningVersion(), b.RunningVersion)
+
+	defer func(v string) { version = v }(version)
+	for _, v := range []string{"1.2.3", "v1.2.3"} {
+		version = v
+		require.Equal(t, "v1.2.3", runningVersion())
+	}
+}
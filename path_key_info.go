@@ -0,0 +1,119 @@
+package alertasecrets
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+
+	"github.com/hmrks/vault-plugin-secrets-alerta/alerta"
+)
+
+// pathKeyInfo extends the Vault API with a `/key-info/<key_id>`
+// endpoint that shows how a key issued by this mount is used.
+func pathKeyInfo(b *alertaBackend) *framework.Path {
+	return &framework.Path{
+		Pattern: "key-info/" + framework.GenericNameRegex("key_id"),
+		Fields: map[string]*framework.FieldSchema{
+			"key_id": {
+				Type:        framework.TypeString,
+				Description: "ID of an Alerta API key issued or imported by this mount",
+				Required:    true,
+			},
+		},
+		Operations: map[logical.Operation]framework.OperationHandler{
+			logical.ReadOperation: &framework.PathOperation{
+				Callback: b.pathKeyInfoRead,
+			},
+		},
+		HelpSynopsis:    pathKeyInfoHelpSyn,
+		HelpDescription: pathKeyInfoHelpDesc,
+	}
+}
+
+// pathKeyInfoRead combines what Vault recorded about an issued key
+// with its details and usage counters from Alerta. The key itself is
+// never returned.
+func (b *alertaBackend) pathKeyInfoRead(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	keyID := d.Get("key_id").(string)
+
+	issued, err := findIssuedKey(ctx, req.Storage, keyID)
+	if err != nil {
+		return nil, err
+	}
+
+	if issued == nil {
+		return logical.ErrorResponse("key %q was not issued by this mount", keyID), nil
+	}
+
+	respData := map[string]interface{}{
+		"key_id":        issued.ID,
+		"role_name":     issued.RoleName,
+		"entity_id":     issued.EntityID,
+		"justification": issued.Justification,
+		"issue_time":    issued.IssueTime,
+		"lease_path":    issued.LeasePath,
+		"imported":      issued.Imported,
+	}
+
+	if !issued.IdleRevokeTime.IsZero() {
+		respData["idle_revoke_time"] = issued.IdleRevokeTime
+	}
+
+	revocation, err := getRevocation(ctx, req.Storage, keyID)
+	if err != nil {
+		return nil, err
+	}
+
+	if revocation != nil {
+		respData["revocation_attempts"] = revocation.Attempts
+		respData["revocation_last_error"] = revocation.LastError
+	}
+
+	resp := &logical.Response{Data: respData}
+
+	client, err := b.getClient(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := client.GetKey(ctx, keyID)
+	if errors.Is(err, alerta.ErrNotFound) {
+		resp.AddWarning(fmt.Sprintf("key %q no longer exists in Alerta", keyID))
+		return resp, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving Alerta API key: %w", err)
+	}
+
+	respData["user"] = key.User
+	respData["scopes"] = key.Scopes
+	respData["customer"] = key.Customer
+	respData["text"] = key.Text
+	respData["count"] = key.Count
+
+	if !key.ExpireTime.IsZero() {
+		respData["expire_time"] = key.ExpireTime
+	}
+
+	if !key.LastUsedTime.IsZero() {
+		respData["last_used_time"] = key.LastUsedTime
+	}
+
+	return resp, nil
+}
+
+const pathKeyInfoHelpSyn = `
+Show the details and usage of an Alerta API key issued by this mount.
+`
+
+const pathKeyInfoHelpDesc = `
+This path returns the scopes, customer, expiry, use count and
+last use time Alerta records for a key, together with the role,
+entity, justification and lease path Vault recorded when the key
+was issued or imported. The key itself is not returned.
+
+Only keys issued or imported by this mount can be looked up.
+`
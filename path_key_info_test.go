@@ -0,0 +1,91 @@
+package alertasecrets
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hmrks/vault-plugin-secrets-alerta/alerta"
+)
+
+func testAlertaKeyInfo(t *testing.T, b *alertaBackend, s logical.Storage, id string) (*logical.Response, error) {
+	t.Helper()
+	return b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "key-info/" + id,
+		Storage:   s,
+	})
+}
+
+func TestAlertaKeyInfo(t *testing.T) {
+	b, s, srv := getTestBackendWithAlerta(t)
+
+	_, err := testAlertaRoleCreate(t, b, s, roleName, map[string]interface{}{
+		"user":   user,
+		"scopes": scopes,
+	})
+	require.NoError(t, err)
+
+	keyResp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation:  logical.UpdateOperation,
+		Path:       "keys/" + roleName,
+		MountPoint: "alerta/",
+		Storage:    s,
+		EntityID:   "entity-a",
+		Data: map[string]interface{}{
+			"justification": "INC-1234",
+		},
+	})
+	require.NoError(t, err)
+	id := keyResp.Data["alerta_api_key_id"].(string)
+
+	lastUsed := time.Now().Add(-time.Minute).UTC()
+	srv.UseKey(id, lastUsed)
+	srv.UseKey(id, lastUsed)
+
+	t.Run("Issued Key", func(t *testing.T) {
+		resp, err := testAlertaKeyInfo(t, b, s, id)
+		require.NoError(t, err)
+		require.False(t, resp.IsError())
+
+		require.Equal(t, id, resp.Data["key_id"])
+		require.Equal(t, roleName, resp.Data["role_name"])
+		require.Equal(t, "entity-a", resp.Data["entity_id"])
+		require.Equal(t, "INC-1234", resp.Data["justification"])
+		require.Equal(t, "alerta/keys/"+roleName, resp.Data["lease_path"])
+		require.Equal(t, false, resp.Data["imported"])
+		require.Equal(t, user, resp.Data["user"])
+		require.Equal(t, scopes, resp.Data["scopes"])
+		require.Equal(t, 2, resp.Data["count"])
+		require.WithinDuration(t, lastUsed, resp.Data["last_used_time"].(time.Time), time.Second)
+		require.NotEmpty(t, resp.Data["expire_time"])
+
+		for _, v := range resp.Data {
+			require.NotEqual(t, keyResp.Data["alerta_api_key"], v)
+		}
+	})
+
+	t.Run("Unknown Key", func(t *testing.T) {
+		srv.AddKey(alerta.Key{ID: "hand-made", User: user, Scopes: scopes})
+
+		resp, err := testAlertaKeyInfo(t, b, s, "hand-made")
+		require.NoError(t, err)
+		require.True(t, resp.IsError())
+	})
+
+	t.Run("Deleted In Alerta", func(t *testing.T) {
+		client, err := b.getClient(context.Background(), s)
+		require.NoError(t, err)
+		err = client.DeleteKey(context.Background(), id)
+		require.NoError(t, err)
+
+		resp, err := testAlertaKeyInfo(t, b, s, id)
+		require.NoError(t, err)
+		require.Equal(t, roleName, resp.Data["role_name"])
+		require.NotContains(t, resp.Data, "count")
+		require.Len(t, resp.Warnings, 1)
+	})
+}
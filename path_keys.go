@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/hashicorp/vault/sdk/framework"
 	"github.com/hashicorp/vault/sdk/logical"
@@ -22,6 +23,20 @@ func pathKeys(b *alertaBackend) *framework.Path {
 				Description: "Name of the role",
 				Required:    true,
 			},
+			"justification": {
+				Type:        framework.TypeString,
+				Description: "Reason for requesting the key, such as an incident or change ticket. Required if the role sets require_justification.",
+			},
+			"request_id": {
+				Type:        framework.TypeString,
+				Description: "ID of an approved key request to redeem, for roles that require approval.",
+			},
+			"format": {
+				Type:          framework.TypeString,
+				Description:   "Format of the rendered field: json (no rendered field), env for ALERTA_ENDPOINT and ALERTA_API_KEY lines, or alerta_conf for an alerta CLI configuration file.",
+				Default:       credentialFormatJSON,
+				AllowedValues: []interface{}{credentialFormatJSON, credentialFormatEnv, credentialFormatAlertaConf},
+			},
 		},
 		Callbacks: map[logical.Operation]framework.OperationFunc{
 			logical.ReadOperation:   b.pathKeysRead,
@@ -48,34 +63,137 @@ func (b *alertaBackend) pathKeysRead(ctx context.Context, req *logical.Request,
 
 	roleEntry.Name = roleName
 
-	return b.createUserCreds(ctx, req, roleEntry)
+	config, err := getConfig(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	// the ceiling may have been tightened after the role was written
+	if err := config.checkScopes(roleEntry.Scopes); err != nil {
+		return logical.ErrorResponse(err.Error()), nil
+	}
+
+	justification := d.Get("justification").(string)
+	requestID := d.Get("request_id").(string)
+
+	format := d.Get("format").(string)
+	if _, err := renderCredentials(format, "", ""); err != nil {
+		return logical.ErrorResponse(err.Error()), nil
+	}
+
+	if requestID != "" && !roleEntry.requiresApproval() {
+		return logical.ErrorResponse("role %q does not require approval", roleName), nil
+	}
+
+	var keyRequest *alertaKeyRequest
+	if requestID != "" {
+		keyRequest, err = b.redeemKeyRequest(ctx, req, roleEntry, requestID)
+		if err != nil {
+			return logical.ErrorResponse(err.Error()), nil
+		}
+		justification = keyRequest.Justification
+	} else {
+		if err := roleEntry.checkJustification(justification); err != nil {
+			return logical.ErrorResponse(err.Error()), nil
+		}
+
+		if roleEntry.requiresApproval() {
+			return b.createKeyRequest(ctx, req, roleEntry, justification)
+		}
+	}
+
+	resp, err := b.createUserCreds(ctx, req, roleEntry, justification)
+	if err != nil {
+		if keyRequest != nil {
+			if restoreErr := b.restoreKeyRequest(ctx, req.Storage, keyRequest); restoreErr != nil {
+				return nil, fmt.Errorf("%w (restoring key request also failed: %v)", err, restoreErr)
+			}
+		}
+		return nil, err
+	}
+
+	if format != credentialFormatJSON {
+		rendered, err := renderCredentials(format, resp.Data["alerta_endpoint"].(string), resp.Data["alerta_api_key"].(string))
+		if err != nil {
+			return nil, err
+		}
+		resp.Data["rendered"] = rendered
+	}
+
+	return resp, nil
+}
+
+const (
+	credentialFormatJSON       = "json"
+	credentialFormatEnv        = "env"
+	credentialFormatAlertaConf = "alerta_conf"
+)
+
+// renderCredentials renders an endpoint and key in a form that alerta
+// CLI and SDK consumers can use directly.
+func renderCredentials(format, endpoint, key string) (string, error) {
+	switch format {
+	case credentialFormatJSON:
+		return "", nil
+	case credentialFormatEnv:
+		return fmt.Sprintf("ALERTA_ENDPOINT=%s\nALERTA_API_KEY=%s\n", endpoint, key), nil
+	case credentialFormatAlertaConf:
+		return fmt.Sprintf("[DEFAULT]\nendpoint = %s\nkey = %s\n", endpoint, key), nil
+	default:
+		return "", fmt.Errorf("invalid format %q: must be one of %s, %s or %s", format, credentialFormatJSON, credentialFormatEnv, credentialFormatAlertaConf)
+	}
 }
 
 // createUserCreds creates a new Alerta API Key to store into the Vault backend, generates
 // a response with the secrets information, and checks the TTL and MaxTTL attributes.
-func (b *alertaBackend) createUserCreds(ctx context.Context, req *logical.Request, role *alertaRoleEntry) (*logical.Response, error) {
+// The role's issue_rate is only charged once its quotas allow the key, and is
+// refunded if the key cannot be created.
+func (b *alertaBackend) createUserCreds(ctx context.Context, req *logical.Request, role *alertaRoleEntry, justification string) (*logical.Response, error) {
+	defer measureSince(time.Now(), role.Name, "key", "issue")
+
 	client, err := b.getClient(ctx, req.Storage)
 	if err != nil {
 		return nil, err
 	}
 
-	key, err := b.createKey(ctx, client, role)
+	text, err := b.keyText(req, role, justification)
 	if err != nil {
 		return nil, err
 	}
 
+	if err := b.reserveKeys(ctx, req.Storage, role, req.EntityID, 1); err != nil {
+		return nil, err
+	}
+
+	if err := b.takeIssueTokens(ctx, req.Storage, role, 1); err != nil {
+		if relErr := b.releaseKeys(ctx, req.Storage, role.Name, req.EntityID, 1); relErr != nil {
+			return nil, fmt.Errorf("%w (releasing quota also failed: %v)", err, relErr)
+		}
+		return nil, err
+	}
+
+	key, err := b.issueKey(ctx, req, client, role, text, justification)
+	if err != nil {
+		if retErr := b.returnIssueTokens(ctx, req.Storage, role, 1); retErr != nil {
+			return nil, fmt.Errorf("%w (returning issue_rate token also failed: %v)", err, retErr)
+		}
+		return nil, err
+	}
+
 	// The response is divided into two objects (1) internal data and (2) data.
 	// If you want to reference any information in your code, you need to
 	// store it in internal data!
 	resp := b.Secret(alertaKeyType).Response(map[string]interface{}{
 		"alerta_api_key":    key.Key,
 		"alerta_api_key_id": key.ID,
+		"alerta_endpoint":   client.Endpoint(),
 		"expire_time":       key.ExpireTime,
 		"role_name":         role.Name,
 	}, map[string]interface{}{
 		"alerta_api_key":    key.Key,
 		"alerta_api_key_id": key.ID,
 		"role_name":         role.Name,
+		"justification":     justification,
 	})
 
 	if role.TTL > 0 {
@@ -89,6 +207,51 @@ func (b *alertaBackend) createUserCreds(ctx context.Context, req *logical.Reques
 	return resp, nil
 }
 
+// issueKey creates a key in Alerta and records it as issued. Quota for
+// the key must already be reserved; it is released again on failure.
+func (b *alertaBackend) issueKey(ctx context.Context, req *logical.Request, client *alertaClient, role *alertaRoleEntry, text string, justification string) (*alertaKey, error) {
+	key, err := b.createKey(ctx, client, role, text)
+	if err != nil {
+		b.Logger().Error("error creating key", "role", role.Name, "entity_id", req.EntityID, "request_id", req.ID, "error", err)
+		incrCounter(role.Name, "key", "issue_failure")
+		if relErr := b.releaseKeys(ctx, req.Storage, role.Name, req.EntityID, 1); relErr != nil {
+			return nil, fmt.Errorf("%w (releasing quota also failed: %v)", err, relErr)
+		}
+		return nil, err
+	}
+
+	if err := setIssuedKey(ctx, req.Storage, &alertaIssuedKey{
+		ID:            key.ID,
+		RoleName:      role.Name,
+		EntityID:      req.EntityID,
+		Justification: justification,
+		IssueTime:     time.Now().UTC(),
+		ExpireTime:    key.ExpireTime,
+		LeasePath:     req.MountPoint + req.Path,
+	}); err != nil {
+		if delErr := b.deleteKey(ctx, client, key.ID); delErr != nil {
+			return nil, fmt.Errorf("error recording issued key: %w (cleanup also failed: %v)", err, delErr)
+		}
+		if relErr := b.releaseKeys(ctx, req.Storage, role.Name, req.EntityID, 1); relErr != nil {
+			return nil, fmt.Errorf("error recording issued key: %w (releasing quota also failed: %v)", err, relErr)
+		}
+		return nil, fmt.Errorf("error recording issued key: %w", err)
+	}
+
+	b.Logger().Info("key issued", "role", role.Name, "key_id", key.ID, "entity_id", req.EntityID,
+		"request_id", req.ID, "expire_time", key.ExpireTime)
+	incrCounter(role.Name, "key", "issued")
+	b.sendEvent(ctx, eventKeyIssue,
+		logical.EventMetadataOperation, string(req.Operation),
+		logical.EventMetadataDataPath, req.Path,
+		"role", role.Name,
+		"key_id", key.ID,
+		"entity_id", req.EntityID,
+		"display_name", req.DisplayName)
+
+	return key, nil
+}
+
 const pathKeysHelpSyn = `
 Generate a Alerta API key from a specific Vault role.
 `
@@ -96,4 +259,9 @@ Generate a Alerta API key from a specific Vault role.
 const pathKeysHelpDesc = `
 This path generates an Alerta API key
 based on a particular role.
+
+For roles that require approval, the first request
+returns a pending request ID instead of a key. Once
+another entity approves it, write the ID as request_id
+to this path to receive the key.
 `
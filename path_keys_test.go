@@ -2,13 +2,18 @@ package alertasecrets
 
 import (
 	"context"
+	"net/http"
 	"os"
+	"strings"
 	"testing"
 	"time"
 
 	log "github.com/hashicorp/go-hclog"
 	"github.com/hashicorp/vault/sdk/helper/logging"
 	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hmrks/vault-plugin-secrets-alerta/alertatest"
 )
 
 // newAcceptanceTestEnv creates a test environment for credentials
@@ -58,3 +63,209 @@ func TestAcceptanceAlertaKey(t *testing.T) {
 	t.Run("read alerta api key", acceptanceTestEnv.ReadAlertaKey)
 	t.Run("cleanup api keys", acceptanceTestEnv.CleanupAlertaKeys)
 }
+
+// testAlertaKeyRead reads a key for the given role and fails
+// the test on an error response.
+func testAlertaKeyRead(t *testing.T, b *alertaBackend, s logical.Storage, role string) (*logical.Response, error) {
+	t.Helper()
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "keys/" + role,
+		Storage:   s,
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	if resp != nil && resp.IsError() {
+		t.Fatal(resp.Error())
+	}
+	return resp, nil
+}
+
+// testAlertaKeyRevoke revokes the lease returned by testAlertaKeyRead.
+func testAlertaKeyRevoke(t *testing.T, b *alertaBackend, s logical.Storage, keyResp *logical.Response) (*logical.Response, error) {
+	t.Helper()
+	return b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.RevokeOperation,
+		Storage:   s,
+		Secret:    keyResp.Secret,
+	})
+}
+
+func TestAlertaKeyRevoke(t *testing.T) {
+	b, s, srv := getTestBackendWithAlerta(t)
+
+	_, err := testAlertaRoleCreate(t, b, s, roleName, map[string]interface{}{
+		"user":   user,
+		"scopes": scopes,
+	})
+	require.NoError(t, err)
+
+	resp, err := testAlertaKeyRead(t, b, s, roleName)
+	require.NoError(t, err)
+	id := resp.Data["alerta_api_key_id"].(string)
+	require.NotNil(t, srv.Key(id))
+
+	_, err = testAlertaKeyRevoke(t, b, s, resp)
+	require.NoError(t, err)
+	require.Nil(t, srv.Key(id))
+
+	issued, err := listIssuedKeys(context.Background(), s, roleName)
+	require.NoError(t, err)
+	require.Empty(t, issued)
+
+	// revoking a key that is already gone from Alerta succeeds
+	_, err = testAlertaKeyRevoke(t, b, s, resp)
+	require.NoError(t, err)
+}
+
+func TestAlertaKeyQuotas(t *testing.T) {
+	b, s, _ := getTestBackendWithAlerta(t)
+
+	_, err := testAlertaRoleCreate(t, b, s, roleName, map[string]interface{}{
+		"user":                       user,
+		"scopes":                     scopes,
+		"max_active_keys":            2,
+		"max_active_keys_per_entity": 1,
+	})
+	require.NoError(t, err)
+
+	readAs := func(entityID string) (*logical.Response, error) {
+		return b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      "keys/" + roleName,
+			Storage:   s,
+			EntityID:  entityID,
+		})
+	}
+
+	first, err := readAs("entity-a")
+	require.NoError(t, err)
+
+	_, err = readAs("entity-a")
+	require.ErrorContains(t, err, "max_active_keys_per_entity: 1 keys active")
+
+	_, err = readAs("entity-b")
+	require.NoError(t, err)
+
+	_, err = readAs("entity-c")
+	require.ErrorContains(t, err, "max_active_keys: 2 keys active")
+
+	_, err = testAlertaKeyRevoke(t, b, s, first)
+	require.NoError(t, err)
+
+	_, err = readAs("entity-c")
+	require.NoError(t, err)
+
+	counter, err := getKeyCounter(context.Background(), s, roleName)
+	require.NoError(t, err)
+	require.Equal(t, 2, counter.Active)
+	require.Equal(t, map[string]int{"entity-b": 1, "entity-c": 1}, counter.Entities)
+}
+
+func TestAlertaKeyJustification(t *testing.T) {
+	b, s, srv := getTestBackendWithAlerta(t)
+
+	_, err := testAlertaRoleCreate(t, b, s, roleName, map[string]interface{}{
+		"user":                  user,
+		"scopes":                scopes,
+		"description":           description,
+		"require_justification": true,
+		"justification_pattern": `INC-\d+`,
+	})
+	require.NoError(t, err)
+
+	requestKey := func(justification string) (*logical.Response, error) {
+		return b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      "keys/" + roleName,
+			Storage:   s,
+			Data:      map[string]interface{}{"justification": justification},
+		})
+	}
+
+	resp, err := requestKey("")
+	require.NoError(t, err)
+	require.True(t, resp.IsError())
+
+	resp, err = requestKey("debugging")
+	require.NoError(t, err)
+	require.True(t, resp.IsError())
+
+	resp, err = requestKey("INC-1234 alert storm")
+	require.NoError(t, err)
+	require.False(t, resp.IsError())
+	require.Equal(t, "INC-1234 alert storm", resp.Secret.InternalData["justification"])
+
+	key := srv.Key(resp.Data["alerta_api_key_id"].(string))
+	require.Contains(t, key.Text, description+" at ")
+	require.True(t, strings.HasSuffix(key.Text, " (INC-1234 alert storm)"))
+}
+
+func TestAlertaKeyFormat(t *testing.T) {
+	b, s, srv := getTestBackendWithAlerta(t)
+
+	_, err := testAlertaRoleCreate(t, b, s, roleName, map[string]interface{}{
+		"user":   user,
+		"scopes": scopes,
+	})
+	require.NoError(t, err)
+
+	readFormat := func(format string) (*logical.Response, error) {
+		return b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      "keys/" + roleName,
+			Storage:   s,
+			Data:      map[string]interface{}{"format": format},
+		})
+	}
+
+	resp, err := readFormat("json")
+	require.NoError(t, err)
+	require.Equal(t, srv.URL, resp.Data["alerta_endpoint"])
+	require.NotContains(t, resp.Data, "rendered")
+
+	resp, err = readFormat("env")
+	require.NoError(t, err)
+	require.Equal(t, "ALERTA_ENDPOINT="+srv.URL+"\nALERTA_API_KEY="+resp.Data["alerta_api_key"].(string)+"\n", resp.Data["rendered"])
+
+	resp, err = readFormat("alerta_conf")
+	require.NoError(t, err)
+	require.Equal(t, "[DEFAULT]\nendpoint = "+srv.URL+"\nkey = "+resp.Data["alerta_api_key"].(string)+"\n", resp.Data["rendered"])
+
+	keys := srv.KeyCount()
+	resp, err = readFormat("yaml")
+	require.NoError(t, err)
+	require.True(t, resp.IsError())
+	require.Equal(t, keys, srv.KeyCount())
+}
+
+func TestAlertaKeyLogging(t *testing.T) {
+	var logs strings.Builder
+	b, s, srv := getTestBackendWithLogger(t, &logs)
+
+	_, err := testAlertaRoleCreate(t, b, s, roleName, map[string]interface{}{
+		"user":   user,
+		"scopes": scopes,
+	})
+	require.NoError(t, err)
+
+	resp, err := testAlertaKeyRead(t, b, s, roleName)
+	require.NoError(t, err)
+	id := resp.Data["alerta_api_key_id"].(string)
+
+	srv.Inject(alertatest.Fault{Method: http.MethodPost, Path: "/key", Status: http.StatusInternalServerError})
+
+	_, err = testAlertaKeyRead(t, b, s, roleName)
+	require.Error(t, err)
+	require.ErrorContains(t, err, "request ID ")
+
+	require.Contains(t, logs.String(), "role written")
+	require.Contains(t, logs.String(), "key issued")
+	require.Contains(t, logs.String(), "key_id="+id)
+	require.Contains(t, logs.String(), "alerta request returned an error")
+	require.NotContains(t, logs.String(), resp.Data["alerta_api_key"].(string))
+	require.NotContains(t, logs.String(), auth_key)
+}
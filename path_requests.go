@@ -0,0 +1,473 @@
+package alertasecrets
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/hashicorp/go-uuid"
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+const (
+	keyRequestStoragePrefix = "requests/"
+
+	keyRequestPending  = "pending"
+	keyRequestApproved = "approved"
+	keyRequestDenied   = "denied"
+	keyRequestRedeemed = "redeemed"
+
+	defaultApprovalWindow = time.Hour
+
+	// keyRequestPendingTTL is how long a request can wait for a
+	// decision before it expires.
+	keyRequestPendingTTL = 24 * time.Hour
+
+	// keyRequestRetention is how long a request is kept after it was
+	// denied, redeemed or expired, so its requester can still read
+	// the outcome.
+	keyRequestRetention = 24 * time.Hour
+
+	// keyRequestSweepInterval is how often finished requests are
+	// deleted from storage.
+	keyRequestSweepInterval = 5 * time.Minute
+)
+
+// alertaKeyRequest is a request for a key from a role that
+// requires approval by a second entity.
+type alertaKeyRequest struct {
+	ID            string `json:"id"`
+	RoleName      string `json:"role_name"`
+	EntityID      string `json:"entity_id"`
+	DisplayName   string `json:"display_name"`
+	Justification string `json:"justification,omitempty"`
+	// User, Customer and Scopes are what the role granted when the
+	// request was made, which is what the approver approves.
+	User         string    `json:"user"`
+	Customer     string    `json:"customer,omitempty"`
+	Scopes       []string  `json:"scopes"`
+	Status       string    `json:"status"`
+	CreateTime   time.Time `json:"create_time"`
+	DecidedBy    string    `json:"decided_by,omitempty"`
+	DecisionTime time.Time `json:"decision_time,omitempty"`
+	RedeemBy     time.Time `json:"redeem_by,omitempty"`
+	RedeemTime   time.Time `json:"redeem_time,omitempty"`
+}
+
+// matchesRole reports whether the role still grants the key the
+// request was made for.
+func (r *alertaKeyRequest) matchesRole(role *alertaRoleEntry) bool {
+	return r.User == role.User && r.Customer == role.Customer &&
+		slices.Equal(slices.Sorted(slices.Values(r.Scopes)), slices.Sorted(slices.Values(role.Scopes)))
+}
+
+// expireTime returns when a pending request expires.
+func (r *alertaKeyRequest) expireTime() time.Time {
+	return r.CreateTime.Add(keyRequestPendingTTL)
+}
+
+// finishTime returns when the request stopped, or will stop, being
+// able to change: when it was denied or redeemed, or when it expires
+// waiting for a decision or to be redeemed.
+func (r *alertaKeyRequest) finishTime() time.Time {
+	switch r.Status {
+	case keyRequestPending:
+		return r.expireTime()
+	case keyRequestApproved:
+		return r.RedeemBy
+	case keyRequestRedeemed:
+		if !r.RedeemTime.IsZero() {
+			return r.RedeemTime
+		}
+	}
+	return r.DecisionTime
+}
+
+// toResponseData returns response data for a key request
+func (r *alertaKeyRequest) toResponseData() map[string]interface{} {
+	respData := map[string]interface{}{
+		"request_id":    r.ID,
+		"role_name":     r.RoleName,
+		"entity_id":     r.EntityID,
+		"display_name":  r.DisplayName,
+		"justification": r.Justification,
+		"user":          r.User,
+		"customer":      r.Customer,
+		"scopes":        r.Scopes,
+		"status":        r.Status,
+		"create_time":   r.CreateTime,
+	}
+
+	if r.DecidedBy != "" {
+		respData["decided_by"] = r.DecidedBy
+		respData["decision_time"] = r.DecisionTime
+	}
+
+	switch r.Status {
+	case keyRequestPending:
+		respData["expire_time"] = r.expireTime()
+	case keyRequestApproved:
+		respData["redeem_by"] = r.RedeemBy
+	case keyRequestRedeemed:
+		if !r.RedeemTime.IsZero() {
+			respData["redeem_time"] = r.RedeemTime
+		}
+	}
+
+	return respData
+}
+
+func getKeyRequest(ctx context.Context, s logical.Storage, id string) (*alertaKeyRequest, error) {
+	entry, err := s.Get(ctx, keyRequestStoragePrefix+id)
+	if err != nil {
+		return nil, err
+	}
+
+	if entry == nil {
+		return nil, nil
+	}
+
+	var keyRequest alertaKeyRequest
+	if err := entry.DecodeJSON(&keyRequest); err != nil {
+		return nil, err
+	}
+	return &keyRequest, nil
+}
+
+func setKeyRequest(ctx context.Context, s logical.Storage, keyRequest *alertaKeyRequest) error {
+	entry, err := logical.StorageEntryJSON(keyRequestStoragePrefix+keyRequest.ID, keyRequest)
+	if err != nil {
+		return err
+	}
+
+	return s.Put(ctx, entry)
+}
+
+// pathRequests extends the Vault API with `/requests` endpoints
+// to review, approve and deny pending key requests.
+func pathRequests(b *alertaBackend) []*framework.Path {
+	return []*framework.Path{
+		{
+			Pattern: "requests/" + framework.GenericNameRegex("id"),
+			Fields: map[string]*framework.FieldSchema{
+				"id": {
+					Type:        framework.TypeString,
+					Description: "ID of the key request",
+					Required:    true,
+				},
+			},
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.ReadOperation: &framework.PathOperation{
+					Callback: b.pathRequestsRead,
+				},
+			},
+			HelpSynopsis:    pathRequestsHelpSynopsis,
+			HelpDescription: pathRequestsHelpDescription,
+		},
+		{
+			Pattern: "requests/" + framework.GenericNameRegex("id") + "/approve",
+			Fields: map[string]*framework.FieldSchema{
+				"id": {
+					Type:        framework.TypeString,
+					Description: "ID of the key request",
+					Required:    true,
+				},
+			},
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.UpdateOperation: &framework.PathOperation{
+					Callback: b.pathRequestsApprove,
+				},
+			},
+			HelpSynopsis:    pathRequestsApproveHelpSynopsis,
+			HelpDescription: pathRequestsApproveHelpDescription,
+		},
+		{
+			Pattern: "requests/" + framework.GenericNameRegex("id") + "/deny",
+			Fields: map[string]*framework.FieldSchema{
+				"id": {
+					Type:        framework.TypeString,
+					Description: "ID of the key request",
+					Required:    true,
+				},
+			},
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.UpdateOperation: &framework.PathOperation{
+					Callback: b.pathRequestsDeny,
+				},
+			},
+			HelpSynopsis:    pathRequestsDenyHelpSynopsis,
+			HelpDescription: pathRequestsDenyHelpDescription,
+		},
+		{
+			Pattern: "requests/?$",
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.ListOperation: &framework.PathOperation{
+					Callback: b.pathRequestsList,
+				},
+			},
+			HelpSynopsis:    pathRequestsListHelpSynopsis,
+			HelpDescription: pathRequestsListHelpDescription,
+		},
+	}
+}
+
+const (
+	pathRequestsHelpSynopsis    = `Read a request for a key from a role that requires approval.`
+	pathRequestsHelpDescription = `
+Requests are created by reading keys/<role> for a role that requires
+approval. A request must be approved by a different entity before the
+requester can redeem it for a key.
+
+A pending request expires if it is not decided within 24 hours.
+Requests are deleted 24 hours after they are denied, redeemed or
+expire.
+`
+
+	pathRequestsApproveHelpSynopsis    = `Approve a pending key request.`
+	pathRequestsApproveHelpDescription = `
+Approves a pending key request. The approving entity must be different
+from the requesting entity. Once approved, the requester can redeem the
+request once, within the role's approval_window, by passing its ID as
+request_id to keys/<role>. If the role's user, customer or scopes have
+changed since the request was made, it can no longer be redeemed.
+`
+
+	pathRequestsDenyHelpSynopsis    = `Deny a pending key request.`
+	pathRequestsDenyHelpDescription = `Denies a pending key request so it can never be redeemed.`
+
+	pathRequestsListHelpSynopsis    = `List the key requests in Alerta backend`
+	pathRequestsListHelpDescription = `Requests will be listed by their ID.`
+)
+
+func (b *alertaBackend) pathRequestsRead(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	keyRequest, err := getKeyRequest(ctx, req.Storage, d.Get("id").(string))
+	if err != nil {
+		return nil, err
+	}
+
+	if keyRequest == nil {
+		return nil, nil
+	}
+
+	return &logical.Response{
+		Data: keyRequest.toResponseData(),
+	}, nil
+}
+
+func (b *alertaBackend) pathRequestsList(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	entries, err := req.Storage.List(ctx, keyRequestStoragePrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	return logical.ListResponse(entries), nil
+}
+
+func (b *alertaBackend) pathRequestsApprove(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	return b.decideKeyRequest(ctx, req, d.Get("id").(string), keyRequestApproved)
+}
+
+func (b *alertaBackend) pathRequestsDeny(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	return b.decideKeyRequest(ctx, req, d.Get("id").(string), keyRequestDenied)
+}
+
+// decideKeyRequest moves a pending request to approved or denied on
+// behalf of an entity other than the requester.
+func (b *alertaBackend) decideKeyRequest(ctx context.Context, req *logical.Request, id string, status string) (*logical.Response, error) {
+	b.requestLock.Lock()
+	defer b.requestLock.Unlock()
+
+	keyRequest, err := getKeyRequest(ctx, req.Storage, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if keyRequest == nil {
+		return logical.ErrorResponse("key request %q not found", id), nil
+	}
+
+	if keyRequest.Status != keyRequestPending {
+		return logical.ErrorResponse("key request %q is %s, not pending", id, keyRequest.Status), nil
+	}
+
+	now := time.Now().UTC()
+	if now.After(keyRequest.expireTime()) {
+		return logical.ErrorResponse("key request %q expired at %s", id, keyRequest.expireTime().Format(time.RFC3339)), nil
+	}
+
+	if req.EntityID == "" {
+		return logical.ErrorResponse("key requests can only be decided by tokens with an identity entity"), nil
+	}
+
+	if req.EntityID == keyRequest.EntityID {
+		return logical.ErrorResponse("key requests must be decided by a different entity than the requester"), nil
+	}
+
+	keyRequest.Status = status
+	keyRequest.DecidedBy = req.EntityID
+	keyRequest.DecisionTime = now
+
+	if status == keyRequestApproved {
+		role, err := b.getRole(ctx, req.Storage, keyRequest.RoleName)
+		if err != nil {
+			return nil, fmt.Errorf("error retrieving role: %w", err)
+		}
+
+		if role == nil {
+			return logical.ErrorResponse("role %q no longer exists", keyRequest.RoleName), nil
+		}
+
+		keyRequest.RedeemBy = now.Add(role.approvalWindow())
+	}
+
+	if err := setKeyRequest(ctx, req.Storage, keyRequest); err != nil {
+		return nil, err
+	}
+
+	b.Logger().Info("key request decided", "request", id, "role", keyRequest.RoleName, "status", status,
+		"requester", keyRequest.EntityID, "decided_by", req.EntityID)
+
+	return &logical.Response{
+		Data: keyRequest.toResponseData(),
+	}, nil
+}
+
+// createKeyRequest records a pending request for a key from a role
+// that requires approval.
+func (b *alertaBackend) createKeyRequest(ctx context.Context, req *logical.Request, role *alertaRoleEntry, justification string) (*logical.Response, error) {
+	if req.EntityID == "" {
+		return logical.ErrorResponse("role %q requires approval and can only be requested by tokens with an identity entity", role.Name), nil
+	}
+
+	id, err := uuid.GenerateUUID()
+	if err != nil {
+		return nil, err
+	}
+
+	keyRequest := &alertaKeyRequest{
+		ID:            id,
+		RoleName:      role.Name,
+		EntityID:      req.EntityID,
+		DisplayName:   req.DisplayName,
+		Justification: justification,
+		User:          role.User,
+		Customer:      role.Customer,
+		Scopes:        role.Scopes,
+		Status:        keyRequestPending,
+		CreateTime:    time.Now().UTC(),
+	}
+
+	if err := setKeyRequest(ctx, req.Storage, keyRequest); err != nil {
+		return nil, err
+	}
+
+	b.Logger().Info("key request created", "request", id, "role", role.Name, "requester", req.EntityID)
+
+	resp := &logical.Response{
+		Data: keyRequest.toResponseData(),
+	}
+	resp.AddWarning(fmt.Sprintf("role %q requires approval; once request %s is approved, redeem it by writing request_id to keys/%s", role.Name, id, role.Name))
+
+	return resp, nil
+}
+
+// redeemKeyRequest marks an approved request as redeemed. It returns
+// the request so its justification can be carried onto the key.
+func (b *alertaBackend) redeemKeyRequest(ctx context.Context, req *logical.Request, role *alertaRoleEntry, id string) (*alertaKeyRequest, error) {
+	b.requestLock.Lock()
+	defer b.requestLock.Unlock()
+
+	keyRequest, err := getKeyRequest(ctx, req.Storage, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if keyRequest == nil || keyRequest.RoleName != role.Name {
+		return nil, fmt.Errorf("key request %q not found for role %q", id, role.Name)
+	}
+
+	if keyRequest.EntityID != req.EntityID {
+		return nil, errors.New("key requests can only be redeemed by the requesting entity")
+	}
+
+	if keyRequest.Status != keyRequestApproved {
+		return nil, fmt.Errorf("key request %q is %s, not approved", id, keyRequest.Status)
+	}
+
+	if time.Now().After(keyRequest.RedeemBy) {
+		return nil, fmt.Errorf("key request %q had to be redeemed by %s", id, keyRequest.RedeemBy.Format(time.RFC3339))
+	}
+
+	// the approval covers the user, customer and scopes the role had
+	// when the request was made, not whatever it grants now
+	if !keyRequest.matchesRole(role) {
+		return nil, fmt.Errorf("role %q has changed since key request %q was made, request a new key", role.Name, id)
+	}
+
+	keyRequest.Status = keyRequestRedeemed
+	keyRequest.RedeemTime = time.Now().UTC()
+	if err := setKeyRequest(ctx, req.Storage, keyRequest); err != nil {
+		return nil, err
+	}
+
+	return keyRequest, nil
+}
+
+// restoreKeyRequest returns a redeemed request to approved, so that
+// it can be redeemed again after the key could not be created.
+func (b *alertaBackend) restoreKeyRequest(ctx context.Context, s logical.Storage, keyRequest *alertaKeyRequest) error {
+	b.requestLock.Lock()
+	defer b.requestLock.Unlock()
+
+	keyRequest.Status = keyRequestApproved
+	keyRequest.RedeemTime = time.Time{}
+	return setKeyRequest(ctx, s, keyRequest)
+}
+
+// deleteFinishedKeyRequests deletes requests that were denied,
+// redeemed or expired more than keyRequestRetention ago.
+func (b *alertaBackend) deleteFinishedKeyRequests(ctx context.Context, s logical.Storage) error {
+	now := time.Now().UTC()
+	if last := b.lastRequestSweep.Load(); last != 0 && now.Sub(time.Unix(0, last)) < keyRequestSweepInterval {
+		return nil
+	}
+	b.lastRequestSweep.Store(now.UnixNano())
+
+	ids, err := s.List(ctx, keyRequestStoragePrefix)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, id := range ids {
+		if err := b.deleteFinishedKeyRequest(ctx, s, id, now); err != nil {
+			errs = append(errs, fmt.Errorf("key request %q: %w", id, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func (b *alertaBackend) deleteFinishedKeyRequest(ctx context.Context, s logical.Storage, id string, now time.Time) error {
+	b.requestLock.Lock()
+	defer b.requestLock.Unlock()
+
+	keyRequest, err := getKeyRequest(ctx, s, id)
+	if err != nil || keyRequest == nil {
+		return err
+	}
+
+	if now.Sub(keyRequest.finishTime()) < keyRequestRetention {
+		return nil
+	}
+
+	if err := s.Delete(ctx, keyRequestStoragePrefix+id); err != nil {
+		return err
+	}
+
+	b.Logger().Debug("deleted finished key request", "request", id, "role", keyRequest.RoleName, "status", keyRequest.Status)
+	return nil
+}
@@ -0,0 +1,232 @@
+package alertasecrets
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAlertaKeyRequests(t *testing.T) {
+	b, s, _ := getTestBackendWithAlerta(t)
+
+	_, err := testAlertaRoleCreate(t, b, s, roleName, map[string]interface{}{
+		"user":             user,
+		"scopes":           scopes,
+		"require_approval": true,
+	})
+	require.NoError(t, err)
+
+	requestKey := func(entityID string, data map[string]interface{}) (*logical.Response, error) {
+		return b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      "keys/" + roleName,
+			Storage:   s,
+			EntityID:  entityID,
+			Data:      data,
+		})
+	}
+
+	decide := func(entityID, id, decision string) (*logical.Response, error) {
+		return b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      "requests/" + id + "/" + decision,
+			Storage:   s,
+			EntityID:  entityID,
+		})
+	}
+
+	t.Run("Request Without Entity", func(t *testing.T) {
+		resp, err := requestKey("", nil)
+		require.NoError(t, err)
+		require.True(t, resp.IsError())
+	})
+
+	var id string
+	t.Run("Create Request", func(t *testing.T) {
+		resp, err := requestKey("requester", nil)
+		require.NoError(t, err)
+		require.Nil(t, resp.Secret)
+		require.Equal(t, keyRequestPending, resp.Data["status"])
+		id = resp.Data["request_id"].(string)
+
+		resp, err = b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.ListOperation,
+			Path:      "requests/",
+			Storage:   s,
+		})
+		require.NoError(t, err)
+		require.Equal(t, []string{id}, resp.Data["keys"])
+	})
+
+	t.Run("Redeem Before Approval", func(t *testing.T) {
+		resp, err := requestKey("requester", map[string]interface{}{"request_id": id})
+		require.NoError(t, err)
+		require.True(t, resp.IsError())
+	})
+
+	t.Run("Self Approval Refused", func(t *testing.T) {
+		resp, err := decide("requester", id, "approve")
+		require.NoError(t, err)
+		require.True(t, resp.IsError())
+	})
+
+	t.Run("Approve", func(t *testing.T) {
+		resp, err := decide("approver", id, "approve")
+		require.NoError(t, err)
+		require.False(t, resp.IsError())
+		require.Equal(t, keyRequestApproved, resp.Data["status"])
+
+		resp, err = decide("approver", id, "deny")
+		require.NoError(t, err)
+		require.True(t, resp.IsError())
+	})
+
+	t.Run("Redeem By Other Entity Refused", func(t *testing.T) {
+		resp, err := requestKey("approver", map[string]interface{}{"request_id": id})
+		require.NoError(t, err)
+		require.True(t, resp.IsError())
+	})
+
+	t.Run("Redeem Once", func(t *testing.T) {
+		resp, err := requestKey("requester", map[string]interface{}{"request_id": id})
+		require.NoError(t, err)
+		require.False(t, resp.IsError())
+		require.NotNil(t, resp.Secret)
+		require.NotEmpty(t, resp.Data["alerta_api_key"])
+
+		resp, err = requestKey("requester", map[string]interface{}{"request_id": id})
+		require.NoError(t, err)
+		require.True(t, resp.IsError())
+	})
+
+	t.Run("Deny", func(t *testing.T) {
+		resp, err := requestKey("requester", nil)
+		require.NoError(t, err)
+		denied := resp.Data["request_id"].(string)
+
+		resp, err = decide("approver", denied, "deny")
+		require.NoError(t, err)
+		require.Equal(t, keyRequestDenied, resp.Data["status"])
+
+		resp, err = requestKey("requester", map[string]interface{}{"request_id": denied})
+		require.NoError(t, err)
+		require.True(t, resp.IsError())
+	})
+
+	t.Run("Role Changed After Approval", func(t *testing.T) {
+		resp, err := requestKey("requester", nil)
+		require.NoError(t, err)
+		require.Equal(t, scopes, resp.Data["scopes"])
+		changed := resp.Data["request_id"].(string)
+
+		_, err = decide("approver", changed, "approve")
+		require.NoError(t, err)
+
+		roleData := map[string]interface{}{
+			"user":             user,
+			"scopes":           append(slices.Clone(scopes), "write:keys"),
+			"require_approval": true,
+		}
+		_, err = testAlertaRoleCreate(t, b, s, roleName, roleData)
+		require.NoError(t, err)
+
+		resp, err = requestKey("requester", map[string]interface{}{"request_id": changed})
+		require.NoError(t, err)
+		require.True(t, resp.IsError())
+		require.Contains(t, resp.Error().Error(), "has changed since key request")
+
+		// the request can be redeemed again once the role grants what
+		// was approved
+		roleData["scopes"] = scopes
+		_, err = testAlertaRoleCreate(t, b, s, roleName, roleData)
+		require.NoError(t, err)
+
+		resp, err = requestKey("requester", map[string]interface{}{"request_id": changed})
+		require.NoError(t, err)
+		require.False(t, resp.IsError())
+		require.NotNil(t, resp.Secret)
+	})
+}
+
+func TestAlertaKeyRequestsAdminScopes(t *testing.T) {
+	b, s, _ := getTestBackendWithAlerta(t)
+
+	err := testConfigUpdate(t, b, s, map[string]interface{}{
+		"allow_admin_scopes": true,
+	})
+	require.NoError(t, err)
+
+	_, err = testAlertaRoleCreate(t, b, s, roleName, map[string]interface{}{
+		"user":   user,
+		"scopes": []string{"admin"},
+	})
+	require.NoError(t, err)
+
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "keys/" + roleName,
+		Storage:   s,
+		EntityID:  "requester",
+	})
+	require.NoError(t, err)
+	require.Nil(t, resp.Secret)
+	require.Equal(t, keyRequestPending, resp.Data["status"])
+}
+
+func TestAlertaKeyRequestsCleanup(t *testing.T) {
+	b, s, _ := getTestBackendWithAlerta(t)
+	ctx := context.Background()
+	now := time.Now().UTC()
+
+	requests := map[string]*alertaKeyRequest{
+		"pending":          {Status: keyRequestPending, CreateTime: now},
+		"pending-expiring": {Status: keyRequestPending, CreateTime: now.Add(-30 * time.Hour)},
+		"pending-expired":  {Status: keyRequestPending, CreateTime: now.Add(-49 * time.Hour)},
+		"approved":         {Status: keyRequestApproved, RedeemBy: now.Add(time.Hour)},
+		"approved-expired": {Status: keyRequestApproved, RedeemBy: now.Add(-25 * time.Hour)},
+		"denied":           {Status: keyRequestDenied, DecisionTime: now.Add(-time.Hour)},
+		"denied-old":       {Status: keyRequestDenied, DecisionTime: now.Add(-25 * time.Hour)},
+		"redeemed":         {Status: keyRequestRedeemed, DecisionTime: now.Add(-26 * time.Hour), RedeemTime: now.Add(-time.Hour)},
+		"redeemed-old":     {Status: keyRequestRedeemed, DecisionTime: now.Add(-26 * time.Hour), RedeemTime: now.Add(-25 * time.Hour)},
+	}
+	for id, keyRequest := range requests {
+		keyRequest.ID = id
+		keyRequest.RoleName = roleName
+		keyRequest.EntityID = "requester"
+		require.NoError(t, setKeyRequest(ctx, s, keyRequest))
+	}
+
+	t.Run("Expired Request Cannot Be Decided", func(t *testing.T) {
+		resp, err := b.HandleRequest(ctx, &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      "requests/pending-expiring/approve",
+			Storage:   s,
+			EntityID:  "approver",
+		})
+		require.NoError(t, err)
+		require.True(t, resp.IsError())
+		require.Contains(t, resp.Error().Error(), "expired")
+	})
+
+	t.Run("Delete Finished", func(t *testing.T) {
+		b.lastRequestSweep.Store(0)
+		require.NoError(t, testPeriodic(t, b, s))
+
+		ids, err := s.List(ctx, keyRequestStoragePrefix)
+		require.NoError(t, err)
+		require.ElementsMatch(t, []string{"pending", "pending-expiring", "approved", "denied", "redeemed"}, ids)
+	})
+
+	t.Run("Sweep Interval", func(t *testing.T) {
+		require.NoError(t, setKeyRequest(ctx, s, &alertaKeyRequest{ID: "late", Status: keyRequestDenied, DecisionTime: now.Add(-25 * time.Hour)}))
+		require.NoError(t, testPeriodic(t, b, s))
+
+		keyRequest, err := getKeyRequest(ctx, s, "late")
+		require.NoError(t, err)
+		require.NotNil(t, keyRequest)
+	})
+}
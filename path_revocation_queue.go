@@ -0,0 +1,323 @@
+package alertasecrets
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+
+	"github.com/hmrks/vault-plugin-secrets-alerta/alerta"
+)
+
+const (
+	revocationQueueStoragePrefix = "revocation-queue/"
+
+	revocationRetryMinBackoff = time.Minute
+	revocationRetryMaxBackoff = time.Hour
+
+	defaultRevocationAlertThreshold   = 5
+	defaultRevocationAlertSeverity    = "major"
+	defaultRevocationAlertEnvironment = "Production"
+	defaultRevocationAlertResource    = alertOrigin
+
+	revocationAlertEvent = "AlertaKeyRevocationFailed"
+)
+
+// alertaRevocationAlertConfig configures the alert raised in Alerta
+// when a revoked key still cannot be deleted after several attempts.
+type alertaRevocationAlertConfig struct {
+	Threshold   int    `json:"threshold"`
+	Severity    string `json:"severity"`
+	Environment string `json:"environment"`
+	Resource    string `json:"resource"`
+}
+
+func (c alertaRevocationAlertConfig) threshold() int {
+	if c.Threshold > 0 {
+		return c.Threshold
+	}
+	return defaultRevocationAlertThreshold
+}
+
+func (c alertaRevocationAlertConfig) severity() string {
+	if c.Severity != "" {
+		return c.Severity
+	}
+	return defaultRevocationAlertSeverity
+}
+
+func (c alertaRevocationAlertConfig) environment() string {
+	if c.Environment != "" {
+		return c.Environment
+	}
+	return defaultRevocationAlertEnvironment
+}
+
+func (c alertaRevocationAlertConfig) resource() string {
+	if c.Resource != "" {
+		return c.Resource
+	}
+	return defaultRevocationAlertResource
+}
+
+// alertaRevocation is a key whose lease was revoked but which could not
+// be deleted from Alerta. It stays queued until a retry succeeds.
+type alertaRevocation struct {
+	KeyID        string    `json:"key_id"`
+	RoleName     string    `json:"role_name"`
+	LeaseID      string    `json:"lease_id"`
+	Attempts     int       `json:"attempts"`
+	FirstFailure time.Time `json:"first_failure"`
+	NextAttempt  time.Time `json:"next_attempt"`
+	LastError    string    `json:"last_error"`
+	Alerted      bool      `json:"alerted"`
+}
+
+// toResponseData returns response data for a queued revocation
+func (r *alertaRevocation) toResponseData() map[string]interface{} {
+	return map[string]interface{}{
+		"role_name":     r.RoleName,
+		"lease_id":      r.LeaseID,
+		"attempts":      r.Attempts,
+		"first_failure": r.FirstFailure,
+		"next_attempt":  r.NextAttempt,
+		"last_error":    r.LastError,
+		"alerted":       r.Alerted,
+	}
+}
+
+// recordFailure counts a failed attempt and schedules the next one,
+// doubling the wait after each failure up to revocationRetryMaxBackoff.
+func (r *alertaRevocation) recordFailure(err error, now time.Time) {
+	r.Attempts++
+	r.LastError = err.Error()
+
+	backoff := revocationRetryMaxBackoff
+	if shift := r.Attempts - 1; shift < 16 {
+		backoff = min(revocationRetryMinBackoff<<shift, revocationRetryMaxBackoff)
+	}
+	r.NextAttempt = now.Add(backoff)
+}
+
+func getRevocation(ctx context.Context, s logical.Storage, id string) (*alertaRevocation, error) {
+	entry, err := s.Get(ctx, revocationQueueStoragePrefix+id)
+	if err != nil {
+		return nil, err
+	}
+
+	if entry == nil {
+		return nil, nil
+	}
+
+	var revocation alertaRevocation
+	if err := entry.DecodeJSON(&revocation); err != nil {
+		return nil, err
+	}
+	return &revocation, nil
+}
+
+func setRevocation(ctx context.Context, s logical.Storage, revocation *alertaRevocation) error {
+	entry, err := logical.StorageEntryJSON(revocationQueueStoragePrefix+revocation.KeyID, revocation)
+	if err != nil {
+		return err
+	}
+
+	return s.Put(ctx, entry)
+}
+
+// queueRevocation records a key that could not be deleted when its
+// lease was revoked, so that the periodic function retries it.
+func (b *alertaBackend) queueRevocation(ctx context.Context, s logical.Storage, role, id, leaseID string, cause error) error {
+	now := time.Now().UTC()
+	revocation := &alertaRevocation{
+		KeyID:        id,
+		RoleName:     role,
+		LeaseID:      leaseID,
+		FirstFailure: now,
+	}
+	revocation.recordFailure(cause, now)
+
+	return setRevocation(ctx, s, revocation)
+}
+
+// retryRevocations retries deleting the queued keys that are due, and
+// raises an alert in Alerta for each key that has failed too often.
+func (b *alertaBackend) retryRevocations(ctx context.Context, s logical.Storage) error {
+	ids, err := s.List(ctx, revocationQueueStoragePrefix)
+	if err != nil {
+		return err
+	}
+
+	if len(ids) == 0 {
+		setRevocationQueueGauge(0)
+		return nil
+	}
+
+	config, err := getConfig(ctx, s)
+	if err != nil {
+		return err
+	}
+
+	if config == nil {
+		config = new(alertaConfig)
+	}
+
+	client, clientErr := b.getClient(ctx, s)
+
+	var errs []error
+	queued := 0
+	now := time.Now().UTC()
+	for _, id := range ids {
+		revocation, err := getRevocation(ctx, s, id)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("error reading queued revocation %q: %w", id, err))
+			continue
+		}
+
+		if revocation == nil {
+			continue
+		}
+
+		if !now.Before(revocation.NextAttempt) {
+			err := clientErr
+			if err == nil {
+				err = b.deleteKey(ctx, client, id)
+			}
+
+			if err == nil || errors.Is(err, alerta.ErrNotFound) {
+				if err := b.completeRevocation(ctx, s, revocation); err != nil {
+					errs = append(errs, err)
+				}
+				continue
+			}
+
+			revocation.recordFailure(err, now)
+			b.Logger().Warn("error retrying key revocation", "role", revocation.RoleName, "key_id", id,
+				"lease_id", revocation.LeaseID, "attempts", revocation.Attempts, "next_attempt", revocation.NextAttempt, "error", err)
+			incrCounter(revocation.RoleName, "key", "revoke_retry_failure")
+		}
+
+		queued++
+
+		if !revocation.Alerted && revocation.Attempts >= config.RevocationAlert.threshold() && clientErr == nil {
+			if err := b.raiseRevocationAlert(ctx, client, config, revocation); err != nil {
+				b.Logger().Error("error raising revocation alert", "role", revocation.RoleName, "key_id", id, "error", err)
+				errs = append(errs, fmt.Errorf("error raising revocation alert for key %q: %w", id, err))
+			} else {
+				revocation.Alerted = true
+			}
+		}
+
+		if err := setRevocation(ctx, s, revocation); err != nil {
+			errs = append(errs, fmt.Errorf("error updating queued revocation %q: %w", id, err))
+		}
+	}
+
+	setRevocationQueueGauge(queued)
+
+	return errors.Join(errs...)
+}
+
+// completeRevocation removes a key from the queue once it is deleted.
+func (b *alertaBackend) completeRevocation(ctx context.Context, s logical.Storage, revocation *alertaRevocation) error {
+	if err := s.Delete(ctx, revocationQueueStoragePrefix+revocation.KeyID); err != nil {
+		return fmt.Errorf("error removing queued revocation %q: %w", revocation.KeyID, err)
+	}
+
+	b.Logger().Info("key revoked", "role", revocation.RoleName, "key_id", revocation.KeyID,
+		"lease_id", revocation.LeaseID, "attempts", revocation.Attempts+1)
+	incrCounter(revocation.RoleName, "key", "revoked")
+	b.sendEvent(ctx, eventKeyRevoke,
+		"role", revocation.RoleName,
+		"key_id", revocation.KeyID,
+		"lease_id", revocation.LeaseID,
+		"attempts", strconv.Itoa(revocation.Attempts+1))
+
+	if revocation.RoleName != "" {
+		if err := b.removeIssuedKey(ctx, s, revocation.RoleName, revocation.KeyID); err != nil {
+			return fmt.Errorf("error removing issued key record: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// raiseRevocationAlert tells on-call through Alerta that a revoked key
+// is still live.
+func (b *alertaBackend) raiseRevocationAlert(ctx context.Context, client *alertaClient, config *alertaConfig, revocation *alertaRevocation) error {
+	text := fmt.Sprintf("Vault could not delete Alerta API key %s of role %q after %d attempts since %s: %s",
+		revocation.KeyID, revocation.RoleName, revocation.Attempts, revocation.FirstFailure.Format(time.RFC3339), revocation.LastError)
+
+	_, err := client.SendAlert(ctx, alerta.AlertRequest{
+		Resource:    config.RevocationAlert.resource(),
+		Event:       revocationAlertEvent,
+		Environment: config.RevocationAlert.environment(),
+		Severity:    config.RevocationAlert.severity(),
+		Service:     []string{"Vault"},
+		Group:       "Vault",
+		Origin:      alertOrigin,
+		Text:        text,
+		Attributes: map[string]interface{}{
+			"keyId":    revocation.KeyID,
+			"role":     revocation.RoleName,
+			"leaseId":  revocation.LeaseID,
+			"attempts": strconv.Itoa(revocation.Attempts),
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	b.Logger().Warn("revocation alert raised", "role", revocation.RoleName, "key_id", revocation.KeyID, "attempts", revocation.Attempts)
+	return nil
+}
+
+// pathRevocationQueue extends the Vault API with a `/revocation-queue`
+// endpoint listing the keys that still have to be deleted.
+func pathRevocationQueue(b *alertaBackend) *framework.Path {
+	return &framework.Path{
+		Pattern: "revocation-queue/?$",
+		Operations: map[logical.Operation]framework.OperationHandler{
+			logical.ListOperation: &framework.PathOperation{
+				Callback: b.pathRevocationQueueList,
+			},
+		},
+		HelpSynopsis:    pathRevocationQueueHelpSynopsis,
+		HelpDescription: pathRevocationQueueHelpDescription,
+	}
+}
+
+func (b *alertaBackend) pathRevocationQueueList(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	ids, err := req.Storage.List(ctx, revocationQueueStoragePrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	keyInfo := make(map[string]interface{}, len(ids))
+	for _, id := range ids {
+		revocation, err := getRevocation(ctx, req.Storage, id)
+		if err != nil {
+			return nil, err
+		}
+
+		if revocation != nil {
+			keyInfo[id] = revocation.toResponseData()
+		}
+	}
+
+	return logical.ListResponseWithInfo(ids, keyInfo), nil
+}
+
+const (
+	pathRevocationQueueHelpSynopsis    = `List the keys that could not be deleted when their lease was revoked.`
+	pathRevocationQueueHelpDescription = `
+Keys are listed by their Alerta key ID. The backend retries deleting
+each key about once a minute at first, backing off to once an hour,
+until Alerta confirms the key is gone. Once a key has failed
+revocation_alert_threshold times, an alert is raised in Alerta.
+`
+)
@@ -0,0 +1,146 @@
+package alertasecrets
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hmrks/vault-plugin-secrets-alerta/alertatest"
+)
+
+func testRevocationQueueList(t *testing.T, b *alertaBackend, s logical.Storage) (*logical.Response, error) {
+	t.Helper()
+	return b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ListOperation,
+		Path:      "revocation-queue/",
+		Storage:   s,
+	})
+}
+
+// testRevocationDue makes a queued revocation due for its next retry.
+func testRevocationDue(t *testing.T, s logical.Storage, id string) {
+	t.Helper()
+
+	revocation, err := getRevocation(context.Background(), s, id)
+	require.NoError(t, err)
+	require.NotNil(t, revocation)
+
+	revocation.NextAttempt = time.Now().Add(-time.Second)
+	require.NoError(t, setRevocation(context.Background(), s, revocation))
+}
+
+func TestAlertaRevocationQueue(t *testing.T) {
+	b, s, srv := getTestBackendWithAlerta(t)
+
+	err := testConfigUpdate(t, b, s, map[string]interface{}{
+		"revocation_alert_threshold":   2,
+		"revocation_alert_environment": "Development",
+	})
+	require.NoError(t, err)
+
+	_, err = testAlertaRoleCreate(t, b, s, roleName, map[string]interface{}{
+		"user":            user,
+		"scopes":          scopes,
+		"max_active_keys": 1,
+	})
+	require.NoError(t, err)
+
+	resp, err := testAlertaKeyRead(t, b, s, roleName)
+	require.NoError(t, err)
+	id := resp.Data["alerta_api_key_id"].(string)
+
+	t.Run("Failed Revoke Is Queued", func(t *testing.T) {
+		srv.Inject(alertatest.Fault{Method: http.MethodDelete, Path: "/key/", Status: http.StatusInternalServerError})
+
+		_, err := testAlertaKeyRevoke(t, b, s, resp)
+		require.NoError(t, err)
+		require.NotNil(t, srv.Key(id))
+
+		listResp, err := testRevocationQueueList(t, b, s)
+		require.NoError(t, err)
+		require.Equal(t, []string{id}, listResp.Data["keys"])
+
+		info := listResp.Data["key_info"].(map[string]interface{})[id].(map[string]interface{})
+		require.Equal(t, roleName, info["role_name"])
+		require.Equal(t, 1, info["attempts"])
+		require.Equal(t, false, info["alerted"])
+		require.NotEmpty(t, info["last_error"])
+
+		// the key is still live, so it still counts towards the quota
+		_, err = testAlertaKeyRead(t, b, s, roleName)
+		require.Error(t, err)
+	})
+
+	t.Run("Retry Waits For Backoff", func(t *testing.T) {
+		require.NoError(t, testPeriodic(t, b, s))
+
+		revocation, err := getRevocation(context.Background(), s, id)
+		require.NoError(t, err)
+		require.Equal(t, 1, revocation.Attempts)
+		require.Empty(t, srv.Alerts())
+	})
+
+	t.Run("Alert After Threshold", func(t *testing.T) {
+		testRevocationDue(t, s, id)
+		require.NoError(t, testPeriodic(t, b, s))
+
+		revocation, err := getRevocation(context.Background(), s, id)
+		require.NoError(t, err)
+		require.Equal(t, 2, revocation.Attempts)
+		require.True(t, revocation.Alerted)
+		require.True(t, revocation.NextAttempt.After(time.Now().Add(time.Minute)))
+
+		alerts := srv.Alerts()
+		require.Len(t, alerts, 1)
+		require.Equal(t, defaultRevocationAlertResource, alerts[0].Resource)
+		require.Equal(t, revocationAlertEvent, alerts[0].Event)
+		require.Equal(t, "Development", alerts[0].Environment)
+		require.Equal(t, defaultRevocationAlertSeverity, alerts[0].Severity)
+		require.Equal(t, id, alerts[0].Attributes["keyId"])
+
+		// the alert is raised once per key
+		testRevocationDue(t, s, id)
+		require.NoError(t, testPeriodic(t, b, s))
+		alerts = srv.Alerts()
+		require.Len(t, alerts, 1)
+		require.Zero(t, alerts[0].DuplicateCount)
+	})
+
+	t.Run("Retry Succeeds", func(t *testing.T) {
+		srv.ClearFaults()
+		testRevocationDue(t, s, id)
+		require.NoError(t, testPeriodic(t, b, s))
+		require.Nil(t, srv.Key(id))
+
+		listResp, err := testRevocationQueueList(t, b, s)
+		require.NoError(t, err)
+		require.Empty(t, listResp.Data["keys"])
+
+		issued, err := getIssuedKey(context.Background(), s, roleName, id)
+		require.NoError(t, err)
+		require.Nil(t, issued)
+
+		_, err = testAlertaKeyRead(t, b, s, roleName)
+		require.NoError(t, err)
+	})
+}
+
+func TestAlertaRevocationBackoff(t *testing.T) {
+	now := time.Now()
+	revocation := &alertaRevocation{}
+
+	expected := []time.Duration{time.Minute, 2 * time.Minute, 4 * time.Minute, 8 * time.Minute, 16 * time.Minute, 32 * time.Minute, time.Hour, time.Hour}
+	for _, backoff := range expected {
+		revocation.recordFailure(context.DeadlineExceeded, now)
+		require.Equal(t, now.Add(backoff), revocation.NextAttempt)
+	}
+
+	for range 100 {
+		revocation.recordFailure(context.DeadlineExceeded, now)
+	}
+	require.Equal(t, now.Add(time.Hour), revocation.NextAttempt)
+}
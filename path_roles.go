@@ -2,37 +2,119 @@ package alertasecrets
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"regexp"
+	"slices"
 	"time"
 
 	"github.com/hashicorp/vault/sdk/framework"
 	"github.com/hashicorp/vault/sdk/logical"
+
+	"github.com/hmrks/vault-plugin-secrets-alerta/alerta"
 )
 
 // alertaRoleEntry defines the data required
 // for a Vault role to access and call the Alerta
 // key endpoints
 type alertaRoleEntry struct {
-	User        string        `json:"user"`
-	Scopes      []string      `json:"scopes"`
-	Description string        `json:"description"`
-	TTL         time.Duration `json:"ttl"`
-	MaxTTL      time.Duration `json:"max_ttl"`
-	Name        string        `json:"name"`
+	User                   string        `json:"user"`
+	Scopes                 []string      `json:"scopes"`
+	Customer               string        `json:"customer"`
+	Description            string        `json:"description"`
+	TTL                    time.Duration `json:"ttl"`
+	MaxTTL                 time.Duration `json:"max_ttl"`
+	PropagateChanges       bool          `json:"propagate_changes"`
+	MaxActiveKeys          int           `json:"max_active_keys"`
+	MaxActiveKeysPerEntity int           `json:"max_active_keys_per_entity"`
+	IssueRate              string        `json:"issue_rate"`
+	IssueBurst             int           `json:"issue_burst"`
+	TextTemplate           string        `json:"text_template"`
+	RequireJustification   bool          `json:"require_justification"`
+	JustificationPattern   string        `json:"justification_pattern"`
+	RequireApproval        bool          `json:"require_approval"`
+	ApprovalWindow         time.Duration `json:"approval_window"`
+	Exclusive              bool          `json:"exclusive"`
+	ExclusiveAction        string        `json:"exclusive_action"`
+	IdleTimeout            time.Duration `json:"idle_timeout"`
+	Name                   string        `json:"name"`
 }
 
 // toResponseData returns response data for a role
 func (r *alertaRoleEntry) toResponseData() map[string]interface{} {
 	respData := map[string]interface{}{
-		"ttl":         r.TTL.Seconds(),
-		"max_ttl":     r.MaxTTL.Seconds(),
-		"user":        r.User,
-		"scopes":      r.Scopes,
-		"description": r.Description,
+		"ttl":                        r.TTL.Seconds(),
+		"max_ttl":                    r.MaxTTL.Seconds(),
+		"user":                       r.User,
+		"scopes":                     r.Scopes,
+		"customer":                   r.Customer,
+		"description":                r.Description,
+		"propagate_changes":          r.PropagateChanges,
+		"max_active_keys":            r.MaxActiveKeys,
+		"max_active_keys_per_entity": r.MaxActiveKeysPerEntity,
+		"issue_rate":                 r.IssueRate,
+		"issue_burst":                r.IssueBurst,
+		"text_template":              r.TextTemplate,
+		"require_justification":      r.RequireJustification,
+		"justification_pattern":      r.JustificationPattern,
+		"require_approval":           r.RequireApproval,
+		"approval_window":            r.ApprovalWindow.Seconds(),
+		"exclusive":                  r.Exclusive,
+		"exclusive_action":           r.exclusiveAction(),
+		"idle_timeout":               r.IdleTimeout.Seconds(),
 	}
 	return respData
 }
 
+// requiresApproval reports whether keys for the role need approval by a
+// second entity. Roles granting admin scopes always do.
+func (r *alertaRoleEntry) requiresApproval() bool {
+	return r.RequireApproval || slices.ContainsFunc(r.Scopes, isAdminScope)
+}
+
+// approvalWindow returns how long an approved request can be redeemed.
+func (r *alertaRoleEntry) approvalWindow() time.Duration {
+	if r.ApprovalWindow > 0 {
+		return r.ApprovalWindow
+	}
+	return defaultApprovalWindow
+}
+
+// exclusiveAction returns what the sweep does with keys of an
+// exclusive role that this mount did not issue.
+func (r *alertaRoleEntry) exclusiveAction() string {
+	if r.ExclusiveAction != "" {
+		return r.ExclusiveAction
+	}
+	return exclusiveActionReport
+}
+
+// checkJustification verifies a caller-supplied justification
+// against the role's requirements.
+func (r *alertaRoleEntry) checkJustification(justification string) error {
+	if justification == "" {
+		if r.RequireJustification {
+			return fmt.Errorf("role %q requires a justification", r.Name)
+		}
+		return nil
+	}
+
+	if r.JustificationPattern == "" {
+		return nil
+	}
+
+	pattern, err := regexp.Compile(r.JustificationPattern)
+	if err != nil {
+		return fmt.Errorf("invalid justification_pattern: %w", err)
+	}
+
+	if !pattern.MatchString(justification) {
+		return fmt.Errorf("justification does not match the pattern %q", r.JustificationPattern)
+	}
+
+	return nil
+}
+
 // pathRole extends the Vault API with a `/role`
 // endpoint for the backend. You can choose whether
 // or not certain attributes should be displayed,
@@ -67,11 +149,68 @@ func pathRole(b *alertaBackend) []*framework.Path {
 					Description: "Scopes for the role",
 					Required:    true,
 				},
+				"customer": {
+					Type:        framework.TypeString,
+					Description: "Customer to associate with generated keys",
+				},
 				"description": {
 					Type:        framework.TypeString,
 					Description: "Description of the role",
 					Default:     "Created by Vault",
 				},
+				"propagate_changes": {
+					Type:        framework.TypeBool,
+					Description: "Apply scope and customer changes to keys already issued for the role. Keys that cannot be updated are revoked.",
+				},
+				"max_active_keys": {
+					Type:        framework.TypeInt,
+					Description: "Maximum number of active keys issued for the role. If not set or set to 0, there is no limit.",
+				},
+				"max_active_keys_per_entity": {
+					Type:        framework.TypeInt,
+					Description: "Maximum number of active keys issued for the role to a single entity. If not set or set to 0, there is no limit.",
+				},
+				"issue_rate": {
+					Type:        framework.TypeString,
+					Description: "Rate at which keys may be issued for the role, as <count>/<s|m|h>, for example 10/m. If not set, there is no limit.",
+				},
+				"issue_burst": {
+					Type:        framework.TypeInt,
+					Description: "Number of keys that may be issued at once before issue_rate applies. If not set or set to 0, defaults to the count of issue_rate.",
+				},
+				"require_justification": {
+					Type:        framework.TypeBool,
+					Description: "Require callers to supply a justification when requesting a key.",
+				},
+				"justification_pattern": {
+					Type:        framework.TypeString,
+					Description: "Regular expression a justification must match, for example INC-\\d+.",
+				},
+				"require_approval": {
+					Type:        framework.TypeBool,
+					Description: "Require a second entity to approve each key request. Always enabled for roles with admin scopes.",
+				},
+				"approval_window": {
+					Type:        framework.TypeDurationSecond,
+					Description: "Time an approved key request can be redeemed. If not set or set to 0, defaults to 1h.",
+				},
+				"exclusive": {
+					Type:        framework.TypeBool,
+					Description: "Treat the role's user and customer as owned by this mount. A periodic sweep finds Alerta keys for them that this mount did not issue.",
+				},
+				"exclusive_action": {
+					Type:          framework.TypeString,
+					Description:   "What the sweep does with keys of an exclusive role that this mount did not issue: report or delete. Defaults to report.",
+					AllowedValues: []interface{}{exclusiveActionReport, exclusiveActionDelete},
+				},
+				"idle_timeout": {
+					Type:        framework.TypeDurationSecond,
+					Description: "Delete keys that Alerta has not seen used for this long, and refuse to renew their leases. If not set or set to 0, keys are never considered idle.",
+				},
+				"text_template": {
+					Type:        framework.TypeString,
+					Description: "Go template for the text of generated keys. Available fields: .Description, .RoleName, .MountPath, .EntityID, .EntityName, .DisplayName, .RequestID, .LeasePath, .Justification and .Time. Defaults to '" + defaultKeyTextTemplate + "'. A justification the template leaves out is appended.",
+				},
 			},
 			Operations: map[logical.Operation]framework.OperationHandler{
 				logical.ReadOperation: &framework.PathOperation{
@@ -187,6 +326,9 @@ func (b *alertaBackend) pathRolesWrite(ctx context.Context, req *logical.Request
 
 	createOperation := (req.Operation == logical.CreateOperation)
 
+	oldScopes := roleEntry.Scopes
+	oldCustomer := roleEntry.Customer
+
 	if user, ok := d.GetOk("user"); ok {
 		roleEntry.User = user.(string)
 	} else if !ok && createOperation {
@@ -199,6 +341,10 @@ func (b *alertaBackend) pathRolesWrite(ctx context.Context, req *logical.Request
 		return nil, fmt.Errorf("scopes is required")
 	}
 
+	if customer, ok := d.GetOk("customer"); ok {
+		roleEntry.Customer = customer.(string)
+	}
+
 	if description, ok := d.GetOk("description"); ok {
 		roleEntry.Description = description.(string)
 	} else if createOperation {
@@ -217,23 +363,227 @@ func (b *alertaBackend) pathRolesWrite(ctx context.Context, req *logical.Request
 		roleEntry.MaxTTL = time.Duration(d.Get("max_ttl").(int)) * time.Second
 	}
 
+	if propagate, ok := d.GetOk("propagate_changes"); ok {
+		roleEntry.PropagateChanges = propagate.(bool)
+	}
+
+	if maxActiveKeys, ok := d.GetOk("max_active_keys"); ok {
+		roleEntry.MaxActiveKeys = maxActiveKeys.(int)
+	}
+
+	if maxActiveKeysPerEntity, ok := d.GetOk("max_active_keys_per_entity"); ok {
+		roleEntry.MaxActiveKeysPerEntity = maxActiveKeysPerEntity.(int)
+	}
+
+	if roleEntry.MaxActiveKeys < 0 || roleEntry.MaxActiveKeysPerEntity < 0 {
+		return logical.ErrorResponse("max_active_keys and max_active_keys_per_entity cannot be negative"), nil
+	}
+
+	if issueRate, ok := d.GetOk("issue_rate"); ok {
+		roleEntry.IssueRate = issueRate.(string)
+	}
+
+	if issueBurst, ok := d.GetOk("issue_burst"); ok {
+		roleEntry.IssueBurst = issueBurst.(int)
+	}
+
+	if exclusive, ok := d.GetOk("exclusive"); ok {
+		roleEntry.Exclusive = exclusive.(bool)
+	}
+
+	if exclusiveAction, ok := d.GetOk("exclusive_action"); ok {
+		roleEntry.ExclusiveAction = exclusiveAction.(string)
+	}
+
+	if idleTimeoutRaw, ok := d.GetOk("idle_timeout"); ok {
+		roleEntry.IdleTimeout = time.Duration(idleTimeoutRaw.(int)) * time.Second
+	}
+
+	if roleEntry.IdleTimeout < 0 {
+		return logical.ErrorResponse("idle_timeout cannot be negative"), nil
+	}
+
+	switch roleEntry.ExclusiveAction {
+	case "", exclusiveActionReport, exclusiveActionDelete:
+	default:
+		return logical.ErrorResponse("exclusive_action must be %s or %s", exclusiveActionReport, exclusiveActionDelete), nil
+	}
+
+	if roleEntry.IssueRate != "" {
+		if _, _, err := parseIssueRate(roleEntry.IssueRate); err != nil {
+			return logical.ErrorResponse(err.Error()), nil
+		}
+	}
+
+	if roleEntry.IssueBurst < 0 {
+		return logical.ErrorResponse("issue_burst cannot be negative"), nil
+	}
+
+	if textTemplate, ok := d.GetOk("text_template"); ok {
+		roleEntry.TextTemplate = textTemplate.(string)
+	}
+
+	if _, err := parseKeyTextTemplate(roleEntry.TextTemplate); err != nil {
+		return logical.ErrorResponse(err.Error()), nil
+	}
+
+	if requireJustification, ok := d.GetOk("require_justification"); ok {
+		roleEntry.RequireJustification = requireJustification.(bool)
+	}
+
+	if justificationPattern, ok := d.GetOk("justification_pattern"); ok {
+		roleEntry.JustificationPattern = justificationPattern.(string)
+	}
+
+	if requireApproval, ok := d.GetOk("require_approval"); ok {
+		roleEntry.RequireApproval = requireApproval.(bool)
+	}
+
+	if approvalWindowRaw, ok := d.GetOk("approval_window"); ok {
+		roleEntry.ApprovalWindow = time.Duration(approvalWindowRaw.(int)) * time.Second
+	}
+
+	if _, err := regexp.Compile(roleEntry.JustificationPattern); err != nil {
+		return logical.ErrorResponse(fmt.Sprintf("invalid justification_pattern: %s", err)), nil
+	}
+
 	if roleEntry.MaxTTL != 0 && roleEntry.TTL > roleEntry.MaxTTL {
 		return logical.ErrorResponse("ttl cannot be greater than max_ttl"), nil
 	}
 
+	config, err := getConfig(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := config.checkScopes(roleEntry.Scopes); err != nil {
+		return logical.ErrorResponse(err.Error()), nil
+	}
+
+	propagate := !createOperation && roleEntry.PropagateChanges &&
+		(!slices.Equal(oldScopes, roleEntry.Scopes) || oldCustomer != roleEntry.Customer)
+
+	var client *alertaClient
+	if propagate {
+		client, err = b.getClient(ctx, req.Storage)
+		if err != nil {
+			return nil, fmt.Errorf("error getting client to propagate role changes: %w", err)
+		}
+	}
+
 	if err := setRole(ctx, req.Storage, name.(string), roleEntry); err != nil {
 		return nil, err
 	}
 
-	return nil, nil
+	b.Logger().Info("role written", "role", name, "user", roleEntry.User, "scopes", roleEntry.Scopes, "customer", roleEntry.Customer)
+	b.sendEvent(ctx, eventRoleWrite,
+		logical.EventMetadataOperation, string(req.Operation),
+		logical.EventMetadataDataPath, req.Path,
+		logical.EventMetadataModified, "true",
+		"role", name.(string),
+		"entity_id", req.EntityID)
+
+	if !propagate {
+		return nil, nil
+	}
+
+	warnings, err := b.propagateRoleChanges(ctx, req.Storage, client, name.(string), roleEntry)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(warnings) == 0 {
+		return nil, nil
+	}
+
+	return &logical.Response{Warnings: warnings}, nil
 }
 
+// propagateRoleChanges applies the role's current scopes and customer to
+// every outstanding key issued for it. Keys that cannot be updated are
+// deleted instead, so a tightened role never leaves broader keys behind.
+func (b *alertaBackend) propagateRoleChanges(ctx context.Context, s logical.Storage, c *alertaClient, name string, r *alertaRoleEntry) ([]string, error) {
+	ids, err := listIssuedKeys(ctx, s, name)
+	if err != nil {
+		return nil, fmt.Errorf("error listing issued keys: %w", err)
+	}
+
+	var warnings []string
+	for _, id := range ids {
+		customer := r.Customer
+		err := c.UpdateKey(ctx, id, alerta.KeyUpdate{Scopes: r.Scopes, Customer: &customer})
+		if err == nil {
+			continue
+		}
+
+		if errors.Is(err, alerta.ErrNotFound) {
+			if err := b.removeIssuedKey(ctx, s, name, id); err != nil {
+				return warnings, err
+			}
+			continue
+		}
+
+		b.Logger().Warn("could not update issued key, revoking it", "role", name, "key_id", id, "error", err)
+
+		if delErr := b.deleteKey(ctx, c, id); delErr != nil && !errors.Is(delErr, alerta.ErrNotFound) {
+			b.Logger().Error("could not revoke issued key", "role", name, "key_id", id, "error", delErr)
+			warnings = append(warnings, fmt.Sprintf("key %s could not be updated (%v) or revoked (%v)", id, err, delErr))
+			continue
+		}
+
+		if err := b.removeIssuedKey(ctx, s, name, id); err != nil {
+			return warnings, err
+		}
+		warnings = append(warnings, fmt.Sprintf("key %s could not be updated and was revoked: %v", id, err))
+	}
+
+	return warnings, nil
+}
+
+// pathRolesDelete deletes a role along with its key counter and rate
+// limit, so that a role created later under the same name starts
+// afresh. A role with outstanding keys cannot be deleted, since their
+// revocation still needs its state.
 func (b *alertaBackend) pathRolesDelete(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
-	err := req.Storage.Delete(ctx, "role/"+d.Get("name").(string))
+	name := d.Get("name").(string)
+
+	b.counterLock.Lock()
+	defer b.counterLock.Unlock()
+
+	issued, err := listIssuedKeys(ctx, req.Storage, name)
+	if err != nil {
+		return nil, fmt.Errorf("error listing issued keys: %w", err)
+	}
+
+	if len(issued) > 0 {
+		return logical.ErrorResponse("role %q has %d outstanding keys, revoke them before deleting the role, for example with 'vault lease revoke -prefix %skeys/%s'",
+			name, len(issued), req.MountPoint, name), nil
+	}
+
+	err = req.Storage.Delete(ctx, "role/"+name)
 	if err != nil {
 		return nil, fmt.Errorf("error deleting alerta role: %w", err)
 	}
 
+	if err := req.Storage.Delete(ctx, keyCounterStoragePrefix+name); err != nil {
+		return nil, fmt.Errorf("error deleting key counter: %w", err)
+	}
+
+	b.rateLimitLock.Lock()
+	err = req.Storage.Delete(ctx, rateLimitStoragePrefix+name)
+	b.rateLimitLock.Unlock()
+	if err != nil {
+		return nil, fmt.Errorf("error deleting rate limit: %w", err)
+	}
+
+	b.Logger().Info("role deleted", "role", d.Get("name").(string))
+	b.sendEvent(ctx, eventRoleDelete,
+		logical.EventMetadataOperation, string(req.Operation),
+		logical.EventMetadataDataPath, req.Path,
+		logical.EventMetadataModified, "true",
+		"role", d.Get("name").(string),
+		"entity_id", req.EntityID)
+
 	return nil, nil
 }
 
@@ -0,0 +1,216 @@
+package alertasecrets
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+
+	"github.com/hmrks/vault-plugin-secrets-alerta/alerta"
+)
+
+const (
+	defaultProbeEndpoint = "/alerts/count"
+
+	dryRunJustification = "role test"
+)
+
+// pathRoleDryRun extends the Vault API with a `/role/<name>/test`
+// endpoint that checks a role works end to end with a probe key.
+func pathRoleDryRun(b *alertaBackend) *framework.Path {
+	return &framework.Path{
+		Pattern: "role/" + framework.GenericNameRegex("name") + "/test",
+		Fields: map[string]*framework.FieldSchema{
+			"name": {
+				Type:        framework.TypeLowerCaseString,
+				Description: "Name of the role",
+				Required:    true,
+			},
+			"probe_endpoint": {
+				Type:        framework.TypeString,
+				Description: "Alerta API endpoint to read with the probe key. It should be allowed by the role's scopes. Defaults to " + defaultProbeEndpoint + ", which needs read:alerts.",
+				Default:     defaultProbeEndpoint,
+			},
+			"justification": {
+				Type:        framework.TypeString,
+				Description: "Reason for testing the role, checked as keys/<role> would. Required if the role sets require_justification. Defaults to '" + dryRunJustification + "'.",
+			},
+		},
+		Operations: map[logical.Operation]framework.OperationHandler{
+			logical.UpdateOperation: &framework.PathOperation{
+				Callback: b.pathRoleDryRunWrite,
+			},
+		},
+		HelpSynopsis:    pathRoleDryRunHelpSyn,
+		HelpDescription: pathRoleDryRunHelpDesc,
+	}
+}
+
+// dryRunStep is the outcome of one step of a role test.
+type dryRunStep struct {
+	Name    string
+	Latency time.Duration
+	Err     error
+	Extra   map[string]interface{}
+}
+
+func (s *dryRunStep) toResponseData() map[string]interface{} {
+	data := map[string]interface{}{
+		"step":       s.Name,
+		"success":    s.Err == nil,
+		"latency_ms": s.Latency.Milliseconds(),
+	}
+
+	if s.Err != nil {
+		data["error"] = s.Err.Error()
+	}
+
+	for k, v := range s.Extra {
+		data[k] = v
+	}
+
+	return data
+}
+
+// pathRoleDryRunWrite creates a key for the role as keys/<role> would,
+// reads probe_endpoint with it and deletes it again. The key draws on
+// the role's issue_rate, but is not leased, does not count towards
+// quotas and is never returned.
+func (b *alertaBackend) pathRoleDryRunWrite(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	roleName := d.Get("name").(string)
+
+	roleEntry, err := b.getRole(ctx, req.Storage, roleName)
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving role: %w", err)
+	}
+
+	if roleEntry == nil {
+		return logical.ErrorResponse("role %q not found", roleName), nil
+	}
+
+	roleEntry.Name = roleName
+
+	// a probe key is issued without a request to approve, so testing
+	// the role would bypass its approval
+	if roleEntry.requiresApproval() {
+		return logical.ErrorResponse("role %q requires approval and cannot be tested", roleName), nil
+	}
+
+	endpoint := d.Get("probe_endpoint").(string)
+	if !strings.HasPrefix(endpoint, "/") {
+		return logical.ErrorResponse("probe_endpoint must start with /"), nil
+	}
+
+	config, err := getConfig(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := config.checkScopes(roleEntry.Scopes); err != nil {
+		return logical.ErrorResponse(err.Error()), nil
+	}
+
+	justification := d.Get("justification").(string)
+	if err := roleEntry.checkJustification(justification); err != nil {
+		return logical.ErrorResponse(err.Error()), nil
+	}
+	if justification == "" {
+		justification = dryRunJustification
+	}
+
+	// otherwise repeated tests could create keys in Alerta faster than
+	// the role allows
+	if err := b.takeIssueTokens(ctx, req.Storage, roleEntry, 1); err != nil {
+		return nil, err
+	}
+
+	client, err := b.getClient(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	text, err := b.keyText(req, roleEntry, justification)
+	if err != nil {
+		return nil, err
+	}
+
+	var steps []*dryRunStep
+
+	start := time.Now()
+	key, err := b.createKey(ctx, client, roleEntry, text)
+	create := &dryRunStep{Name: "create", Latency: time.Since(start), Err: err}
+	steps = append(steps, create)
+
+	if err != nil {
+		if retErr := b.returnIssueTokens(ctx, req.Storage, roleEntry, 1); retErr != nil {
+			return nil, fmt.Errorf("%w (returning issue_rate token also failed: %v)", err, retErr)
+		}
+	}
+
+	if key != nil {
+		create.Extra = map[string]interface{}{"key_id": key.ID}
+
+		start = time.Now()
+		status, err := client.probe(ctx, key.Key, endpoint)
+		steps = append(steps, &dryRunStep{Name: "probe", Latency: time.Since(start), Err: err, Extra: map[string]interface{}{
+			"endpoint": endpoint,
+			"status":   status,
+		}})
+
+		start = time.Now()
+		err = b.deleteKey(ctx, client, key.ID)
+		if errors.Is(err, alerta.ErrNotFound) {
+			err = nil
+		}
+		steps = append(steps, &dryRunStep{Name: "delete", Latency: time.Since(start), Err: err})
+
+		if err != nil {
+			if qErr := b.queueRevocation(ctx, req.Storage, roleName, key.ID, "", err); qErr != nil {
+				return nil, fmt.Errorf("error deleting probe key %s: %w (queueing retry also failed: %v)", key.ID, err, qErr)
+			}
+		}
+	}
+
+	success := true
+	stepData := make([]map[string]interface{}, 0, len(steps))
+	for _, step := range steps {
+		success = success && step.Err == nil
+		stepData = append(stepData, step.toResponseData())
+	}
+
+	b.Logger().Info("role tested", "role", roleName, "success", success, "entity_id", req.EntityID)
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"role_name": roleName,
+			"success":   success,
+			"steps":     stepData,
+		},
+	}, nil
+}
+
+const pathRoleDryRunHelpSyn = `
+Test a role by issuing, using and deleting a probe key.
+`
+
+const pathRoleDryRunHelpDesc = `
+This path creates an Alerta API key exactly as keys/<role> would,
+reads probe_endpoint with it, and deletes it again. It reports
+the result and latency of each step, so that a bad scope or a
+change to Alerta's permissions shows up before senders use the
+role.
+
+The probe key's justification is checked against the role's
+require_justification and justification_pattern, and defaults to
+"role test". It draws on the role's issue_rate like any other key,
+but is not leased, does not count towards the role's quotas and is
+never returned. If it cannot be deleted, it is
+added to the revocation queue.
+
+Roles that require approval cannot be tested, since the probe
+key would be issued without one.
+`
@@ -0,0 +1,172 @@
+package alertasecrets
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hmrks/vault-plugin-secrets-alerta/alertatest"
+)
+
+func testAlertaRoleDryRun(t *testing.T, b *alertaBackend, s logical.Storage, d map[string]interface{}) (*logical.Response, error) {
+	t.Helper()
+	return b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "role/" + roleName + "/test",
+		Data:      d,
+		Storage:   s,
+	})
+}
+
+// testDryRunStep returns the response data of the named step, or nil.
+func testDryRunStep(resp *logical.Response, name string) map[string]interface{} {
+	for _, step := range resp.Data["steps"].([]map[string]interface{}) {
+		if step["step"] == name {
+			return step
+		}
+	}
+	return nil
+}
+
+func TestAlertaRoleDryRun(t *testing.T) {
+	b, s, srv := getTestBackendWithAlerta(t)
+
+	_, err := testAlertaRoleCreate(t, b, s, roleName, map[string]interface{}{
+		"user":            user,
+		"scopes":          "read:alerts,write:alerts",
+		"max_active_keys": 1,
+	})
+	require.NoError(t, err)
+
+	t.Run("Success", func(t *testing.T) {
+		resp, err := testAlertaRoleDryRun(t, b, s, nil)
+		require.NoError(t, err)
+		require.Equal(t, true, resp.Data["success"])
+		require.Len(t, resp.Data["steps"], 3)
+
+		require.Equal(t, true, testDryRunStep(resp, "create")["success"])
+		require.Equal(t, 200, testDryRunStep(resp, "probe")["status"])
+		require.Equal(t, defaultProbeEndpoint, testDryRunStep(resp, "probe")["endpoint"])
+		require.Equal(t, true, testDryRunStep(resp, "delete")["success"])
+		require.Zero(t, srv.KeyCount())
+
+		// probe keys are not leased and do not use up quota
+		counter, err := getKeyCounter(context.Background(), s, roleName)
+		require.NoError(t, err)
+		require.Zero(t, counter.Active)
+	})
+
+	t.Run("Forbidden Probe", func(t *testing.T) {
+		_, err := testAlertaRoleUpdate(t, b, s, map[string]interface{}{
+			"scopes": "write:heartbeats",
+		})
+		require.NoError(t, err)
+
+		resp, err := testAlertaRoleDryRun(t, b, s, nil)
+		require.NoError(t, err)
+		require.Equal(t, false, resp.Data["success"])
+
+		probe := testDryRunStep(resp, "probe")
+		require.Equal(t, false, probe["success"])
+		require.Equal(t, 403, probe["status"])
+		require.Contains(t, probe["error"], "403")
+
+		require.Equal(t, true, testDryRunStep(resp, "delete")["success"])
+		require.Zero(t, srv.KeyCount())
+	})
+
+	t.Run("Failed Create", func(t *testing.T) {
+		srv.Inject(alertatest.Fault{Method: http.MethodPost, Path: "/key", Status: http.StatusInternalServerError})
+
+		resp, err := testAlertaRoleDryRun(t, b, s, nil)
+		require.NoError(t, err)
+		require.Equal(t, false, resp.Data["success"])
+		require.Len(t, resp.Data["steps"], 1)
+		require.NotEmpty(t, testDryRunStep(resp, "create")["error"])
+	})
+
+	t.Run("Failed Delete Is Queued", func(t *testing.T) {
+		srv.ClearFaults()
+		srv.Inject(alertatest.Fault{Method: http.MethodDelete, Path: "/key/", Status: http.StatusInternalServerError})
+
+		resp, err := testAlertaRoleDryRun(t, b, s, map[string]interface{}{
+			"probe_endpoint": "/alerts/count?status=open",
+		})
+		require.NoError(t, err)
+		require.Equal(t, false, resp.Data["success"])
+
+		id := testDryRunStep(resp, "create")["key_id"].(string)
+		require.NotNil(t, srv.Key(id))
+
+		revocation, err := getRevocation(context.Background(), s, id)
+		require.NoError(t, err)
+		require.NotNil(t, revocation)
+	})
+}
+
+func TestAlertaRoleDryRunRequiresApproval(t *testing.T) {
+	b, s, srv := getTestBackendWithAlerta(t)
+
+	require.NoError(t, testConfigUpdate(t, b, s, map[string]interface{}{
+		"allow_admin_scopes": true,
+	}))
+
+	for name, role := range map[string]map[string]interface{}{
+		"Require Approval": {"user": user, "scopes": scopes, "require_approval": true},
+		"Admin Scopes":     {"user": user, "scopes": "admin:keys"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := testAlertaRoleCreate(t, b, s, roleName, role)
+			require.NoError(t, err)
+
+			resp, err := testAlertaRoleDryRun(t, b, s, nil)
+			require.NoError(t, err)
+			require.True(t, resp.IsError())
+			require.Contains(t, resp.Error().Error(), "requires approval")
+			require.Zero(t, srv.KeyCount())
+		})
+	}
+}
+
+func TestAlertaRoleDryRunLimits(t *testing.T) {
+	b, s, srv := getTestBackendWithAlerta(t)
+
+	_, err := testAlertaRoleCreate(t, b, s, roleName, map[string]interface{}{
+		"user":                  user,
+		"scopes":                "read:alerts,write:alerts",
+		"issue_rate":            "1/h",
+		"require_justification": true,
+		"justification_pattern": "^INC-[0-9]+$",
+	})
+	require.NoError(t, err)
+
+	t.Run("Justification Checked", func(t *testing.T) {
+		for _, justification := range []string{"", "role test"} {
+			resp, err := testAlertaRoleDryRun(t, b, s, map[string]interface{}{"justification": justification})
+			require.NoError(t, err)
+			require.True(t, resp.IsError(), justification)
+		}
+		require.Zero(t, srv.KeyCount())
+	})
+
+	t.Run("Issue Rate", func(t *testing.T) {
+		resp, err := testAlertaRoleDryRun(t, b, s, map[string]interface{}{"justification": "INC-1234"})
+		require.NoError(t, err)
+		require.Equal(t, true, resp.Data["success"])
+
+		_, err = testAlertaRoleDryRun(t, b, s, map[string]interface{}{"justification": "INC-1234"})
+		require.ErrorContains(t, err, "exceeded its issue_rate")
+
+		// the test drew on the same bucket as keys/<role>
+		_, err = b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      "keys/" + roleName,
+			Storage:   s,
+			Data:      map[string]interface{}{"justification": "INC-1234"},
+		})
+		require.ErrorContains(t, err, "exceeded its issue_rate")
+	})
+}
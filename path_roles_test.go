@@ -2,11 +2,14 @@ package alertasecrets
 
 import (
 	"context"
+	"net/http"
 	"strconv"
 	"testing"
 
 	"github.com/hashicorp/vault/sdk/logical"
 	"github.com/stretchr/testify/require"
+
+	"github.com/hmrks/vault-plugin-secrets-alerta/alertatest"
 )
 
 const (
@@ -156,3 +159,135 @@ func testAlertaRoleDelete(t *testing.T, b *alertaBackend, s logical.Storage) (*l
 		Storage:   s,
 	})
 }
+
+func TestAlertaRolePropagateChanges(t *testing.T) {
+	b, s, srv := getTestBackendWithAlerta(t)
+
+	_, err := testAlertaRoleCreate(t, b, s, roleName, map[string]interface{}{
+		"user":              user,
+		"scopes":            []string{"write:alerts", "write:heartbeats"},
+		"propagate_changes": true,
+	})
+	require.NoError(t, err)
+
+	var ids []string
+	for i := 0; i < 2; i++ {
+		resp, err := testAlertaKeyRead(t, b, s, roleName)
+		require.NoError(t, err)
+		ids = append(ids, resp.Data["alerta_api_key_id"].(string))
+	}
+	srv.Inject(alertatest.Fault{Method: http.MethodPut, Path: "/key/" + ids[1], Status: http.StatusInternalServerError})
+
+	t.Run("Update Scopes", func(t *testing.T) {
+		resp, err := testAlertaRoleUpdate(t, b, s, map[string]interface{}{
+			"scopes":   []string{"write:alerts"},
+			"customer": "acme",
+		})
+		require.NoError(t, err)
+		require.NotNil(t, resp)
+		require.Len(t, resp.Warnings, 1)
+
+		updated := srv.Key(ids[0])
+		require.NotNil(t, updated)
+		require.Equal(t, []string{"write:alerts"}, updated.Scopes)
+		require.Equal(t, "acme", updated.Customer)
+
+		require.Nil(t, srv.Key(ids[1]))
+
+		issued, err := listIssuedKeys(context.Background(), s, roleName)
+		require.NoError(t, err)
+		require.Equal(t, []string{ids[0]}, issued)
+	})
+
+	t.Run("Update Without Scope Change", func(t *testing.T) {
+		resp, err := testAlertaRoleUpdate(t, b, s, map[string]interface{}{
+			"ttl": "1m",
+		})
+		require.NoError(t, err)
+		require.Nil(t, resp)
+	})
+}
+
+func TestAlertaRoleScopeCeiling(t *testing.T) {
+	b, s, _ := getTestBackendWithAlerta(t)
+
+	t.Run("Admin Scope Refused", func(t *testing.T) {
+		resp, err := testAlertaRoleCreate(t, b, s, roleName, map[string]interface{}{
+			"user":   user,
+			"scopes": []string{"admin"},
+		})
+		require.NoError(t, err)
+		require.True(t, resp.IsError())
+	})
+
+	t.Run("Key Refused After Ceiling Tightened", func(t *testing.T) {
+		resp, err := testAlertaRoleCreate(t, b, s, roleName, map[string]interface{}{
+			"user":   user,
+			"scopes": []string{"write:heartbeats"},
+		})
+		require.NoError(t, err)
+		require.Nil(t, resp)
+
+		err = testConfigUpdate(t, b, s, map[string]interface{}{
+			"allowed_scopes": "write:alerts",
+		})
+		require.NoError(t, err)
+
+		resp, err = b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      "keys/" + roleName,
+			Storage:   s,
+		})
+		require.NoError(t, err)
+		require.True(t, resp.IsError())
+	})
+}
+
+func TestAlertaRoleDeleteState(t *testing.T) {
+	b, s, _ := getTestBackendWithAlerta(t)
+
+	roleData := map[string]interface{}{
+		"user":            user,
+		"scopes":          scopes,
+		"max_active_keys": 1,
+		"issue_rate":      "1/h",
+	}
+	_, err := testAlertaRoleCreate(t, b, s, roleName, roleData)
+	require.NoError(t, err)
+
+	keyResp, err := testAlertaKeyRead(t, b, s, roleName)
+	require.NoError(t, err)
+
+	t.Run("Outstanding Keys", func(t *testing.T) {
+		resp, err := testAlertaRoleDelete(t, b, s)
+		require.NoError(t, err)
+		require.True(t, resp.IsError())
+		require.Contains(t, resp.Error().Error(), "1 outstanding keys")
+
+		role, err := b.getRole(context.Background(), s, roleName)
+		require.NoError(t, err)
+		require.NotNil(t, role)
+	})
+
+	t.Run("Recreated Role Starts Afresh", func(t *testing.T) {
+		_, err := testAlertaKeyRevoke(t, b, s, keyResp)
+		require.NoError(t, err)
+
+		resp, err := testAlertaRoleDelete(t, b, s)
+		require.NoError(t, err)
+		require.Nil(t, resp)
+
+		for _, path := range []string{keyCounterStoragePrefix + roleName, rateLimitStoragePrefix + roleName} {
+			entry, err := s.Get(context.Background(), path)
+			require.NoError(t, err)
+			require.Nil(t, entry, path)
+		}
+
+		// the old role used up its only issue_rate token
+		_, err = testAlertaRoleCreate(t, b, s, roleName, roleData)
+		require.NoError(t, err)
+
+		_, err = testAlertaKeyRead(t, b, s, roleName)
+		require.NoError(t, err)
+	})
+}
@@ -0,0 +1,44 @@
+package alertasecrets
+
+import (
+	"context"
+	"errors"
+
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+// periodicFunc runs the backend's background tasks. Vault invokes it
+// roughly once a minute.
+func (b *alertaBackend) periodicFunc(ctx context.Context, req *logical.Request) error {
+	var errs []error
+
+	if err := b.sendHeartbeat(ctx, req.Storage); err != nil {
+		errs = append(errs, err)
+	}
+
+	if err := b.detectAlertaVersion(ctx, req.Storage); err != nil {
+		errs = append(errs, err)
+	}
+
+	if err := b.retryRevocations(ctx, req.Storage); err != nil {
+		errs = append(errs, err)
+	}
+
+	if err := b.sweepUnmanagedKeys(ctx, req.Storage); err != nil {
+		errs = append(errs, err)
+	}
+
+	if err := b.revokeIdleKeys(ctx, req.Storage); err != nil {
+		errs = append(errs, err)
+	}
+
+	if err := b.checkAuthKeyExpiry(ctx, req.Storage); err != nil {
+		errs = append(errs, err)
+	}
+
+	if err := b.deleteFinishedKeyRequests(ctx, req.Storage); err != nil {
+		errs = append(errs, err)
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,107 @@
+package alertasecrets
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+const (
+	keyCounterStoragePrefix = "counters/"
+)
+
+// alertaKeyCounter tracks how many keys issued for a role are
+// still active, in total and per entity.
+type alertaKeyCounter struct {
+	Active   int            `json:"active"`
+	Entities map[string]int `json:"entities,omitempty"`
+}
+
+func getKeyCounter(ctx context.Context, s logical.Storage, role string) (*alertaKeyCounter, error) {
+	entry, err := s.Get(ctx, keyCounterStoragePrefix+role)
+	if err != nil {
+		return nil, err
+	}
+
+	counter := &alertaKeyCounter{}
+	if entry != nil {
+		if err := entry.DecodeJSON(counter); err != nil {
+			return nil, fmt.Errorf("error reading key counter: %w", err)
+		}
+	}
+
+	if counter.Entities == nil {
+		counter.Entities = map[string]int{}
+	}
+
+	return counter, nil
+}
+
+func setKeyCounter(ctx context.Context, s logical.Storage, role string, counter *alertaKeyCounter) error {
+	entry, err := logical.StorageEntryJSON(keyCounterStoragePrefix+role, counter)
+	if err != nil {
+		return err
+	}
+
+	return s.Put(ctx, entry)
+}
+
+// reserveKeys counts n new keys against the role's quotas, or returns
+// an error naming the limit that would be exceeded.
+func (b *alertaBackend) reserveKeys(ctx context.Context, s logical.Storage, role *alertaRoleEntry, entityID string, n int) error {
+	b.counterLock.Lock()
+	defer b.counterLock.Unlock()
+
+	counter, err := getKeyCounter(ctx, s, role.Name)
+	if err != nil {
+		return err
+	}
+
+	if role.MaxActiveKeys > 0 && counter.Active+n > role.MaxActiveKeys {
+		return logical.CodedError(http.StatusTooManyRequests, fmt.Sprintf(
+			"role %q has reached max_active_keys: %d keys active, limit is %d",
+			role.Name, counter.Active, role.MaxActiveKeys))
+	}
+
+	if role.MaxActiveKeysPerEntity > 0 && counter.Entities[entityID]+n > role.MaxActiveKeysPerEntity {
+		return logical.CodedError(http.StatusTooManyRequests, fmt.Sprintf(
+			"role %q has reached max_active_keys_per_entity: %d keys active for entity %q, limit is %d",
+			role.Name, counter.Entities[entityID], entityID, role.MaxActiveKeysPerEntity))
+	}
+
+	counter.Active += n
+	counter.Entities[entityID] += n
+
+	if err := setKeyCounter(ctx, s, role.Name, counter); err != nil {
+		return err
+	}
+
+	setActiveKeysGauge(role.Name, counter.Active)
+	return nil
+}
+
+// releaseKeys returns n keys to the role's quotas.
+func (b *alertaBackend) releaseKeys(ctx context.Context, s logical.Storage, role string, entityID string, n int) error {
+	b.counterLock.Lock()
+	defer b.counterLock.Unlock()
+
+	counter, err := getKeyCounter(ctx, s, role)
+	if err != nil {
+		return err
+	}
+
+	counter.Active = max(counter.Active-n, 0)
+	counter.Entities[entityID] = max(counter.Entities[entityID]-n, 0)
+	if counter.Entities[entityID] == 0 {
+		delete(counter.Entities, entityID)
+	}
+
+	if err := setKeyCounter(ctx, s, role, counter); err != nil {
+		return err
+	}
+
+	setActiveKeysGauge(role, counter.Active)
+	return nil
+}
@@ -0,0 +1,138 @@
+package alertasecrets
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+const (
+	rateLimitStoragePrefix = "ratelimit/"
+)
+
+// alertaRateLimitState is the token bucket of a role. It is kept in
+// storage so every node of the cluster draws from the same bucket.
+type alertaRateLimitState struct {
+	Tokens  float64   `json:"tokens"`
+	Updated time.Time `json:"updated"`
+}
+
+// parseIssueRate parses a rate such as "10/m" into the number of keys
+// and the interval they are spread over. The unit may be s, m or h.
+func parseIssueRate(rate string) (int, time.Duration, error) {
+	countRaw, unit, ok := strings.Cut(rate, "/")
+	if !ok {
+		return 0, 0, fmt.Errorf("invalid issue_rate %q: expected <count>/<s|m|h>", rate)
+	}
+
+	count, err := strconv.Atoi(strings.TrimSpace(countRaw))
+	if err != nil || count <= 0 {
+		return 0, 0, fmt.Errorf("invalid issue_rate %q: count must be a positive integer", rate)
+	}
+
+	var interval time.Duration
+	switch strings.TrimSpace(unit) {
+	case "s":
+		interval = time.Second
+	case "m":
+		interval = time.Minute
+	case "h":
+		interval = time.Hour
+	default:
+		return 0, 0, fmt.Errorf("invalid issue_rate %q: unit must be s, m or h", rate)
+	}
+
+	return count, interval, nil
+}
+
+// issueBurst returns the size of the role's bucket, or 0 if the role
+// has no issue_rate.
+func (r *alertaRoleEntry) issueBurst() (int, error) {
+	if r.IssueRate == "" {
+		return 0, nil
+	}
+
+	if r.IssueBurst > 0 {
+		return r.IssueBurst, nil
+	}
+
+	count, _, err := parseIssueRate(r.IssueRate)
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// takeIssueTokens removes n tokens from the role's bucket, or returns a
+// retryable error if not enough tokens have accumulated yet.
+func (b *alertaBackend) takeIssueTokens(ctx context.Context, s logical.Storage, role *alertaRoleEntry, n int) error {
+	return b.updateIssueTokens(ctx, s, role, -float64(n))
+}
+
+// returnIssueTokens puts back n tokens taken for keys that could not
+// be created, up to the size of the bucket.
+func (b *alertaBackend) returnIssueTokens(ctx context.Context, s logical.Storage, role *alertaRoleEntry, n int) error {
+	return b.updateIssueTokens(ctx, s, role, float64(n))
+}
+
+// updateIssueTokens refills the role's bucket for the time since it
+// was last updated and adds delta tokens to it. A negative delta
+// fails with a retryable error if the bucket holds too few tokens.
+func (b *alertaBackend) updateIssueTokens(ctx context.Context, s logical.Storage, role *alertaRoleEntry, delta float64) error {
+	if role.IssueRate == "" {
+		return nil
+	}
+
+	count, interval, err := parseIssueRate(role.IssueRate)
+	if err != nil {
+		return err
+	}
+
+	burstSize, err := role.issueBurst()
+	if err != nil {
+		return err
+	}
+	burst := float64(burstSize)
+	perSecond := float64(count) / interval.Seconds()
+
+	b.rateLimitLock.Lock()
+	defer b.rateLimitLock.Unlock()
+
+	entry, err := s.Get(ctx, rateLimitStoragePrefix+role.Name)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	state := &alertaRateLimitState{Tokens: burst, Updated: now}
+	if entry != nil {
+		if err := entry.DecodeJSON(state); err != nil {
+			return fmt.Errorf("error reading rate limit state: %w", err)
+		}
+		elapsed := now.Sub(state.Updated).Seconds()
+		state.Tokens = math.Min(burst, state.Tokens+math.Max(elapsed, 0)*perSecond)
+		state.Updated = now
+	}
+
+	if state.Tokens+delta < 0 {
+		wait := time.Duration(-(state.Tokens + delta) / perSecond * float64(time.Second))
+		return logical.CodedError(http.StatusTooManyRequests, fmt.Sprintf(
+			"role %q has exceeded its issue_rate of %s, retry in %s",
+			role.Name, role.IssueRate, wait.Round(time.Second)))
+	}
+
+	state.Tokens = math.Min(burst, state.Tokens+delta)
+
+	entry, err = logical.StorageEntryJSON(rateLimitStoragePrefix+role.Name, state)
+	if err != nil {
+		return err
+	}
+
+	return s.Put(ctx, entry)
+}
@@ -0,0 +1,91 @@
+package alertasecrets
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hmrks/vault-plugin-secrets-alerta/alertatest"
+)
+
+func TestParseIssueRate(t *testing.T) {
+	count, interval, err := parseIssueRate("10/m")
+	require.NoError(t, err)
+	require.Equal(t, 10, count)
+	require.Equal(t, time.Minute, interval)
+
+	for _, rate := range []string{"10", "0/m", "-1/s", "x/s", "10/d"} {
+		_, _, err := parseIssueRate(rate)
+		require.Error(t, err, rate)
+	}
+}
+
+func TestAlertaKeyIssueRate(t *testing.T) {
+	b, s, _ := getTestBackendWithAlerta(t)
+
+	resp, err := testAlertaRoleCreate(t, b, s, roleName, map[string]interface{}{
+		"user":       user,
+		"scopes":     scopes,
+		"issue_rate": "10/d",
+	})
+	require.NoError(t, err)
+	require.True(t, resp.IsError())
+
+	_, err = testAlertaRoleCreate(t, b, s, roleName, map[string]interface{}{
+		"user":        user,
+		"scopes":      scopes,
+		"issue_rate":  "1/h",
+		"issue_burst": 2,
+	})
+	require.NoError(t, err)
+
+	for i := 0; i < 2; i++ {
+		_, err := testAlertaKeyRead(t, b, s, roleName)
+		require.NoError(t, err)
+	}
+
+	_, err = testAlertaKeyRead(t, b, s, roleName)
+	require.ErrorContains(t, err, "exceeded its issue_rate of 1/h")
+
+	var coded logical.HTTPCodedError
+	require.ErrorAs(t, err, &coded)
+	require.Equal(t, http.StatusTooManyRequests, coded.Code())
+}
+
+func TestAlertaKeyIssueRateRefund(t *testing.T) {
+	b, s, srv := getTestBackendWithAlerta(t)
+
+	_, err := testAlertaRoleCreate(t, b, s, roleName, map[string]interface{}{
+		"user":            user,
+		"scopes":          scopes,
+		"issue_rate":      "2/h",
+		"max_active_keys": 1,
+	})
+	require.NoError(t, err)
+
+	first, err := testAlertaKeyRead(t, b, s, roleName)
+	require.NoError(t, err)
+
+	t.Run("Quota Refusal", func(t *testing.T) {
+		_, err := testAlertaKeyRead(t, b, s, roleName)
+		require.ErrorContains(t, err, "max_active_keys")
+
+		_, err = testAlertaKeyRevoke(t, b, s, first)
+		require.NoError(t, err)
+	})
+
+	t.Run("Failed Create", func(t *testing.T) {
+		srv.Inject(alertatest.Fault{Method: http.MethodPost, Path: "/key", Times: 1, Status: http.StatusInternalServerError})
+		defer srv.ClearFaults()
+
+		_, err := testAlertaKeyRead(t, b, s, roleName)
+		require.ErrorContains(t, err, "500")
+	})
+
+	// neither failure used up the second token
+	_, err = testAlertaKeyRead(t, b, s, roleName)
+	require.NoError(t, err)
+}
@@ -0,0 +1,94 @@
+package alertasecrets
+
+import (
+	"fmt"
+	"strings"
+)
+
+// scopeCovers reports whether scope is equal to, or a narrower form of,
+// parent. Alerta scopes have the form "<permission>[:<resource>]", so
+// "write" covers "write:alerts" but not the other way around.
+func scopeCovers(parent, scope string) bool {
+	return scope == parent || strings.HasPrefix(scope, parent+":")
+}
+
+// scopePermissions lists Alerta's permissions from the strongest, each
+// of which implies the ones after it for the same resource.
+var scopePermissions = []string{"admin", "write", "read"}
+
+// impliedScopes returns scope along with the weaker scopes Alerta
+// grants with it, so "write:keys" also returns "read:keys" and
+// "admin" also returns "write" and "read".
+func impliedScopes(scope string) []string {
+	permission, resource, hasResource := strings.Cut(scope, ":")
+
+	scopes := []string{scope}
+	for i, p := range scopePermissions {
+		if p != permission {
+			continue
+		}
+		for _, weaker := range scopePermissions[i+1:] {
+			if hasResource {
+				weaker += ":" + resource
+			}
+			scopes = append(scopes, weaker)
+		}
+	}
+	return scopes
+}
+
+// scopeGrants reports whether holding parent grants scope, directly
+// or through a scope it implies.
+func scopeGrants(parent, scope string) bool {
+	for _, implied := range impliedScopes(parent) {
+		if scopeCovers(implied, scope) {
+			return true
+		}
+	}
+	return false
+}
+
+// isAdminScope reports whether scope grants any admin permission.
+func isAdminScope(scope string) bool {
+	return scopeCovers("admin", scope)
+}
+
+// checkScopes verifies that every scope stays within the mount-wide
+// ceiling defined by the configuration, including the weaker scopes
+// it implies. A nil configuration still refuses admin scopes.
+func (c *alertaConfig) checkScopes(scopes []string) error {
+	if c == nil {
+		c = new(alertaConfig)
+	}
+
+	for _, scope := range scopes {
+		if isAdminScope(scope) && !c.AllowAdminScopes {
+			return fmt.Errorf("scope %q is an admin scope and allow_admin_scopes is not enabled", scope)
+		}
+
+		for _, implied := range impliedScopes(scope) {
+			for _, denied := range c.DeniedScopes {
+				if scopeCovers(denied, implied) || scopeCovers(implied, denied) {
+					return fmt.Errorf("scope %q is denied by denied_scopes, as it grants %q", scope, denied)
+				}
+			}
+		}
+
+		if len(c.AllowedScopes) == 0 {
+			continue
+		}
+
+		allowed := false
+		for _, parent := range c.AllowedScopes {
+			if scopeGrants(parent, scope) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return fmt.Errorf("scope %q is not within allowed_scopes", scope)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,55 @@
+package alertasecrets
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckScopes(t *testing.T) {
+	cases := []struct {
+		name   string
+		config *alertaConfig
+		scopes []string
+		ok     bool
+	}{
+		{"no config", nil, []string{"write:alerts"}, true},
+		{"admin refused by default", nil, []string{"admin"}, false},
+		{"admin sub-scope refused by default", &alertaConfig{}, []string{"admin:keys"}, false},
+		{"admin allowed", &alertaConfig{AllowAdminScopes: true}, []string{"admin:keys"}, true},
+		{"within allowed", &alertaConfig{AllowedScopes: []string{"write"}}, []string{"write:alerts"}, true},
+		{"outside allowed", &alertaConfig{AllowedScopes: []string{"write:alerts"}}, []string{"write"}, false},
+		{"denied", &alertaConfig{DeniedScopes: []string{"write:keys"}}, []string{"write:keys"}, false},
+		{"broader than denied", &alertaConfig{DeniedScopes: []string{"write:keys"}}, []string{"write"}, false},
+		{"unrelated to denied", &alertaConfig{DeniedScopes: []string{"write:keys"}}, []string{"write:alerts"}, true},
+		{"admin outside allowed", &alertaConfig{AllowAdminScopes: true, AllowedScopes: []string{"read"}}, []string{"admin"}, false},
+		{"write implies denied read", &alertaConfig{DeniedScopes: []string{"read:keys"}}, []string{"write:keys"}, false},
+		{"broad write implies denied read", &alertaConfig{DeniedScopes: []string{"read:keys"}}, []string{"write"}, false},
+		{"admin implies denied write", &alertaConfig{AllowAdminScopes: true, DeniedScopes: []string{"write:keys"}}, []string{"admin:keys"}, false},
+		{"admin implies denied read", &alertaConfig{AllowAdminScopes: true, DeniedScopes: []string{"read:keys"}}, []string{"admin"}, false},
+		{"denied read leaves other resources", &alertaConfig{DeniedScopes: []string{"read:keys"}}, []string{"write:alerts"}, true},
+		{"read within allowed write", &alertaConfig{AllowedScopes: []string{"write"}}, []string{"read:alerts"}, true},
+		{"read within allowed write resource", &alertaConfig{AllowedScopes: []string{"write:alerts"}}, []string{"read:alerts"}, true},
+		{"read of other resource outside allowed", &alertaConfig{AllowedScopes: []string{"write:alerts"}}, []string{"read:keys"}, false},
+		{"write outside allowed read", &alertaConfig{AllowedScopes: []string{"read"}}, []string{"write:alerts"}, false},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.config.checkScopes(tc.scopes)
+			if tc.ok {
+				require.NoError(t, err)
+			} else {
+				require.Error(t, err)
+			}
+		})
+	}
+}
+
+func TestImpliedScopes(t *testing.T) {
+	require.Equal(t, []string{"admin", "write", "read"}, impliedScopes("admin"))
+	require.Equal(t, []string{"admin:keys", "write:keys", "read:keys"}, impliedScopes("admin:keys"))
+	require.Equal(t, []string{"write:alerts", "read:alerts"}, impliedScopes("write:alerts"))
+	require.Equal(t, []string{"read"}, impliedScopes("read"))
+	require.Equal(t, []string{"delete:alerts"}, impliedScopes("delete:alerts"))
+}
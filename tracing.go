@@ -0,0 +1,91 @@
+package alertasecrets
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/hmrks/vault-plugin-secrets-alerta/alerta"
+)
+
+const tracerName = "github.com/hmrks/vault-plugin-secrets-alerta"
+
+// newTracerProvider creates a tracer provider exporting spans to
+// the OTLP/HTTP endpoint, for example http://collector:4318.
+func newTracerProvider(ctx context.Context, endpoint string) (*sdktrace.TracerProvider, error) {
+	exporter, err := otlptracehttp.New(ctx, otlptracehttp.WithEndpointURL(endpoint))
+	if err != nil {
+		return nil, fmt.Errorf("error creating OTLP exporter: %w", err)
+	}
+
+	res := resource.NewSchemaless(attribute.String("service.name", "vault-plugin-secrets-alerta"))
+
+	return sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	), nil
+}
+
+// tracingTransport wraps an http.RoundTripper so that each call to
+// Alerta produces a client span and carries W3C trace context headers.
+type tracingTransport struct {
+	base       http.RoundTripper
+	tracer     trace.Tracer
+	propagator propagation.TextMapPropagator
+}
+
+func newTracingTransport(base http.RoundTripper, tp trace.TracerProvider) *tracingTransport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	return &tracingTransport{
+		base:       base,
+		tracer:     tp.Tracer(tracerName),
+		propagator: propagation.TraceContext{},
+	}
+}
+
+func (t *tracingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	endpoint := metricEndpoint(req.URL.Path)
+
+	ctx, span := t.tracer.Start(req.Context(), fmt.Sprintf("alerta %s %s", req.Method, endpoint),
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("http.request.method", req.Method),
+			attribute.String("url.path", endpoint),
+			attribute.String("server.address", req.URL.Host),
+			attribute.String("alerta.request_id", req.Header.Get(alerta.RequestIDHeader)),
+		),
+	)
+	defer span.End()
+
+	req = req.Clone(ctx)
+	t.propagator.Inject(ctx, propagation.HeaderCarrier(req.Header))
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+
+	span.SetAttributes(attribute.Int("http.response.status_code", resp.StatusCode))
+	if requestID := resp.Header.Get(alerta.RequestIDHeader); requestID != "" {
+		span.SetAttributes(attribute.String("alerta.request_id", requestID))
+	}
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		span.SetStatus(codes.Error, http.StatusText(resp.StatusCode))
+	}
+
+	return resp, nil
+}
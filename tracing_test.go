@@ -0,0 +1,47 @@
+package alertasecrets
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"github.com/hmrks/vault-plugin-secrets-alerta/alerta"
+)
+
+func TestTracingTransport(t *testing.T) {
+	var traceparent string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get("traceparent")
+		w.Header().Set(alerta.RequestIDHeader, "alerta-request-1")
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer srv.Close()
+
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	client, err := alerta.NewClient(srv.URL, auth_key, alerta.WithHTTPClient(&http.Client{
+		Transport: newTracingTransport(nil, tp),
+	}))
+	require.NoError(t, err)
+
+	err = client.DeleteKey(context.Background(), "1234")
+	require.ErrorIs(t, err, alerta.ErrNotFound)
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 1)
+	span := spans[0]
+
+	require.Equal(t, "alerta DELETE /key/:id", span.Name())
+	require.Contains(t, span.Attributes(), attribute.Int("http.response.status_code", http.StatusNotFound))
+	require.Contains(t, span.Attributes(), attribute.String("alerta.request_id", "alerta-request-1"))
+
+	require.NotEmpty(t, traceparent)
+	require.Contains(t, traceparent, span.SpanContext().TraceID().String())
+}
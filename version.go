@@ -0,0 +1,16 @@
+package alertasecrets
+
+import "strings"
+
+// version and commit identify the plugin build. Releases set them
+// with -X ldflags in .goreleaser.yaml.
+var (
+	version = "0.0.0-dev"
+	commit  = ""
+)
+
+// runningVersion returns the build version in the form Vault expects,
+// with a leading v.
+func runningVersion() string {
+	return "v" + strings.TrimPrefix(version, "v")
+}